@@ -0,0 +1,217 @@
+package caddy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/client"
+)
+
+// acmeSecretLabel marks the secrets the global options refer to.
+const acmeSecretLabel = "dockboy.caddy.acme"
+
+// ACME configures how Caddy obtains certificates. The zero value uses
+// Caddy's defaults: Let's Encrypt and ZeroSSL with the HTTP and TLS-ALPN
+// challenges.
+type ACME struct {
+	Email string
+	// CA is the directory URL of the ACME CA, e.g. the Let's Encrypt
+	// staging environment or a local Pebble server.
+	CA string
+	// CARoot is a PEM encoded root certificate to trust when connecting to
+	// the CA, for CAs with a private root such as Pebble.
+	CARoot []byte
+	// DNS solves the DNS challenge. Its provider must be built into the
+	// Caddy image, see Settings.Modules.
+	DNS *DNSProvider
+	// OnDemandAsk is the URL Caddy asks whether it may obtain a certificate
+	// for a domain on demand.
+	OnDemandAsk string
+}
+
+type DNSProvider struct {
+	Name string
+	// Credentials are stored as secrets and passed to the provider as
+	// {file.*} placeholders, so they never show up in the Caddy config.
+	Credentials map[string][]byte
+}
+
+var identifierPattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+func (a ACME) empty() bool {
+	return a.Email == "" && a.CA == "" && len(a.CARoot) == 0 && a.DNS == nil && a.OnDemandAsk == ""
+}
+
+func validateACME(a ACME) error {
+	if a.Email != "" && (!strings.Contains(a.Email, "@") || strings.ContainsAny(a.Email, " \t\r\n\"{}")) {
+		return fmt.Errorf("invalid ACME email: %q", a.Email)
+	}
+	if a.CA != "" {
+		if err := validateURL(a.CA); err != nil {
+			return fmt.Errorf("invalid ACME CA: %w", err)
+		}
+	}
+	if len(a.CARoot) > 0 {
+		if _, err := parseCertificate(a.CARoot); err != nil {
+			return fmt.Errorf("invalid ACME CA root: %w", err)
+		}
+	}
+	if a.OnDemandAsk != "" {
+		if err := validateURL(a.OnDemandAsk); err != nil {
+			return fmt.Errorf("invalid on-demand ask endpoint: %w", err)
+		}
+	}
+
+	if a.DNS != nil {
+		if !identifierPattern.MatchString(a.DNS.Name) {
+			return fmt.Errorf("invalid DNS provider: %q", a.DNS.Name)
+		}
+		for key := range a.DNS.Credentials {
+			if !identifierPattern.MatchString(key) {
+				return fmt.Errorf("invalid DNS provider credential: %q", key)
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s is not an HTTP URL", s)
+	}
+	if strings.ContainsAny(s, " \t\r\n\"{}") {
+		return fmt.Errorf("%s contains invalid characters", s)
+	}
+	return nil
+}
+
+// acmeSecretNames returns the secrets the ACME settings need, keyed by name.
+func acmeSecretNames(a ACME) map[string][]byte {
+	secrets := make(map[string][]byte)
+	if len(a.CARoot) > 0 {
+		secrets[secretName("acme-ca-root", a.CARoot)] = a.CARoot
+	}
+	if a.DNS != nil {
+		for key, value := range a.DNS.Credentials {
+			secrets[dnsSecretName(a.DNS.Name, key, value)] = value
+		}
+	}
+	return secrets
+}
+
+func dnsSecretName(provider, key string, value []byte) string {
+	return secretName("acme-"+provider+"-"+strings.ReplaceAll(key, "_", "-"), value)
+}
+
+// acmeSecrets returns the secrets of the ACME settings, creating missing
+// ones if create is set.
+func acmeSecrets(ctx context.Context, remote *client.Client, a ACME, create bool) ([]swarm.Secret, error) {
+	wanted := acmeSecretNames(a)
+	if create {
+		for name, data := range wanted {
+			if _, err := createSecret(ctx, remote, name, data, acmeSecretLabel, map[string]string{acmeSecretLabel: "true"}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	all, err := remote.SecretList(ctx, types.SecretListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", acmeSecretLabel)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ACME secrets: %w", err)
+	}
+
+	var secrets []swarm.Secret
+	for _, secret := range all {
+		if _, ok := wanted[secret.Spec.Name]; ok {
+			secrets = append(secrets, secret)
+		}
+	}
+
+	sort.Slice(secrets, func(i, j int) bool {
+		return secrets[i].Spec.Name < secrets[j].Spec.Name
+	})
+
+	return secrets, nil
+}
+
+// removeUnusedACMESecrets removes the secrets of previous ACME settings once
+// the Caddy service no longer mounts them.
+func removeUnusedACMESecrets(ctx context.Context, remote *client.Client, a ACME) error {
+	wanted := acmeSecretNames(a)
+
+	all, err := remote.SecretList(ctx, types.SecretListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", acmeSecretLabel)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list ACME secrets: %w", err)
+	}
+
+	for _, secret := range all {
+		if _, ok := wanted[secret.Spec.Name]; ok {
+			continue
+		}
+		if err := remote.SecretRemove(ctx, secret.ID); err != nil {
+			slog.WarnContext(ctx, "Failed to remove ACME secret", "secret", secret.Spec.Name, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// generateGlobalOptions renders the global options block of the Caddyfile.
+func generateGlobalOptions(a ACME) string {
+	if a.empty() {
+		return ""
+	}
+
+	var b strings.Builder
+	if a.Email != "" {
+		fmt.Fprintf(&b, "email %s\n", a.Email)
+	}
+	if a.CA != "" {
+		fmt.Fprintf(&b, "acme_ca %s\n", a.CA)
+	}
+	if len(a.CARoot) > 0 {
+		fmt.Fprintf(&b, "acme_ca_root %s\n", path.Join(containerSecretsDir, secretName("acme-ca-root", a.CARoot)))
+	}
+	if a.DNS != nil {
+		keys := make([]string, 0, len(a.DNS.Credentials))
+		for key := range a.DNS.Credentials {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var body string
+		for _, key := range keys {
+			name := dnsSecretName(a.DNS.Name, key, a.DNS.Credentials[key])
+			body += fmt.Sprintf("%s {file.%s}\n", key, path.Join(containerSecretsDir, name))
+		}
+
+		if body == "" {
+			fmt.Fprintf(&b, "acme_dns %s\n", a.DNS.Name)
+		} else {
+			fmt.Fprintf(&b, "acme_dns %s {\n%s}\n", a.DNS.Name, indent(body))
+		}
+	}
+	if a.OnDemandAsk != "" {
+		fmt.Fprintf(&b, "on_demand_tls {\n\task %s\n}\n", a.OnDemandAsk)
+	}
+
+	return "{\n" + indent(b.String()) + "}\n\n"
+}
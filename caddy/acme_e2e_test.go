@@ -0,0 +1,223 @@
+//go:build e2e
+
+package caddy
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// The end-to-end tests run Caddy with the generated config against Pebble, a
+// test ACME CA, on the Docker daemon of DOCKER_HOST:
+//
+//	go test -tags e2e ./caddy/
+const (
+	pebbleImage       = "ghcr.io/letsencrypt/pebble:latest"
+	challtestsrvImage = "ghcr.io/letsencrypt/pebble-challtestsrv:latest"
+
+	e2eSubnet      = "10.30.50.0/24"
+	pebbleIP       = "10.30.50.2"
+	challtestsrvIP = "10.30.50.3"
+	caddyIP        = "10.30.50.4"
+	e2eDomain      = "app.dockboy.test"
+)
+
+// pebbleConfig validates challenges on the standard ports instead of the
+// ones of Pebble's own test setup.
+const pebbleConfig = `{
+  "pebble": {
+    "listenAddress": "0.0.0.0:14000",
+    "managementListenAddress": "0.0.0.0:15000",
+    "certificate": "test/certs/localhost/cert.pem",
+    "privateKey": "test/certs/localhost/key.pem",
+    "httpPort": 80,
+    "tlsPort": 443
+  }
+}
+`
+
+func TestACMEPebble(t *testing.T) {
+	ctx := context.Background()
+
+	docker, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		t.Fatalf("failed to create Docker client: %v", err)
+	}
+	t.Cleanup(func() { docker.Close() })
+
+	networkName := fmt.Sprintf("dockboy-e2e-%d", time.Now().UnixNano())
+	resp, err := docker.NetworkCreate(ctx, networkName, network.CreateOptions{
+		IPAM: &network.IPAM{Config: []network.IPAMConfig{{Subnet: e2eSubnet}}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create network: %v", err)
+	}
+	t.Cleanup(func() { docker.NetworkRemove(context.Background(), resp.ID) })
+
+	// Every domain resolves to Caddy, so Pebble validates the challenges
+	// against it.
+	runContainer(t, docker, networkName, challtestsrvIP, &container.Config{
+		Image: challtestsrvImage,
+		Cmd:   []string{"-defaultIPv6", "", "-defaultIPv4", caddyIP},
+	}, nil)
+
+	pebble := runContainer(t, docker, networkName, pebbleIP, &container.Config{
+		Image: pebbleImage,
+		Cmd:   []string{"-config", "test/config/dockboy.json", "-dnsserver", challtestsrvIP + ":8053"},
+		Env:   []string{"PEBBLE_VA_NOSLEEP=1"},
+	}, map[string][]byte{
+		"test/config/dockboy.json": []byte(pebbleConfig),
+	}, "pebble")
+
+	acme := ACME{
+		Email:  "ops@dockboy.test",
+		CA:     "https://pebble:14000/dir",
+		CARoot: readContainerFile(t, docker, pebble, "/test/certs/pebble.minica.pem"),
+	}
+	if err := validateACME(acme); err != nil {
+		t.Fatalf("validateACME() = %v", err)
+	}
+
+	sites := []site{{
+		App:       "web",
+		Configs:   []ProxyConfig{{Address: e2eDomain}},
+		Upstreams: []Upstream{{Service: "localhost:8080", Weight: 100}},
+	}}
+	content, err := generateCaddyfileContent(sites)
+	if err != nil {
+		t.Fatalf("generateCaddyfileContent() = %v", err)
+	}
+
+	// The secrets are copied to where Swarm would mount them.
+	files := map[string][]byte{
+		"etc/caddy/Caddyfile": []byte(generateGlobalOptions(acme) + content),
+	}
+	for name, data := range acmeSecretNames(acme) {
+		files[path.Join(strings.TrimPrefix(containerSecretsDir, "/"), name)] = data
+	}
+
+	caddy := runContainer(t, docker, networkName, caddyIP, &container.Config{
+		Image: Settings{}.ImageRef(),
+	}, files)
+
+	deadline := time.Now().Add(2 * time.Minute)
+	for {
+		logs := containerLogs(t, docker, caddy)
+		if strings.Contains(logs, "certificate obtained successfully") && strings.Contains(logs, e2eDomain) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Caddy did not obtain a certificate for %s from Pebble:\n%s", e2eDomain, logs)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// runContainer starts a container with a fixed address on the network, with
+// files copied into it first, and removes it when the test ends.
+func runContainer(t *testing.T, docker *client.Client, networkName, ip string, config *container.Config, files map[string][]byte, aliases ...string) string {
+	t.Helper()
+	ctx := context.Background()
+
+	reader, err := docker.ImagePull(ctx, config.Image, image.PullOptions{})
+	if err != nil {
+		t.Fatalf("failed to pull %s: %v", config.Image, err)
+	}
+	_, err = io.Copy(io.Discard, reader)
+	reader.Close()
+	if err != nil {
+		t.Fatalf("failed to pull %s: %v", config.Image, err)
+	}
+
+	resp, err := docker.ContainerCreate(ctx, config, &container.HostConfig{}, &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{
+			networkName: {
+				IPAMConfig: &network.EndpointIPAMConfig{IPv4Address: ip},
+				Aliases:    aliases,
+			},
+		},
+	}, nil, "")
+	if err != nil {
+		t.Fatalf("failed to create %s container: %v", config.Image, err)
+	}
+	t.Cleanup(func() {
+		docker.ContainerRemove(context.Background(), resp.ID, container.RemoveOptions{Force: true})
+	})
+
+	if len(files) > 0 {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for name, data := range files {
+			if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data))}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tw.Write(data); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := docker.CopyToContainer(ctx, resp.ID, "/", &buf, container.CopyToContainerOptions{}); err != nil {
+			t.Fatalf("failed to copy files into %s container: %v", config.Image, err)
+		}
+	}
+
+	if err := docker.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		t.Fatalf("failed to start %s container: %v", config.Image, err)
+	}
+
+	return resp.ID
+}
+
+func readContainerFile(t *testing.T, docker *client.Client, containerID, file string) []byte {
+	t.Helper()
+
+	reader, _, err := docker.CopyFromContainer(context.Background(), containerID, file)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", file, err)
+	}
+	defer reader.Close()
+
+	tr := tar.NewReader(reader)
+	if _, err := tr.Next(); err != nil {
+		t.Fatalf("failed to read %s: %v", file, err)
+	}
+	data, err := io.ReadAll(tr)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", file, err)
+	}
+
+	return data
+}
+
+func containerLogs(t *testing.T, docker *client.Client, containerID string) string {
+	t.Helper()
+
+	reader, err := docker.ContainerLogs(context.Background(), containerID, container.LogsOptions{ShowStdout: true, ShowStderr: true})
+	if err != nil {
+		t.Fatalf("failed to read container logs: %v", err)
+	}
+	defer reader.Close()
+
+	var logs bytes.Buffer
+	if _, err := stdcopy.StdCopy(&logs, &logs, reader); err != nil {
+		t.Fatalf("failed to read container logs: %v", err)
+	}
+
+	return logs.String()
+}
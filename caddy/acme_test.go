@@ -0,0 +1,140 @@
+package caddy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestGenerateGlobalOptions(t *testing.T) {
+	root := testCertificate(t)
+
+	tests := []struct {
+		name string
+		acme ACME
+		want string
+	}{
+		{
+			name: "defaults",
+			want: "",
+		},
+		{
+			name: "email",
+			acme: ACME{Email: "ops@example.com"},
+			want: "{\n" +
+				"\temail ops@example.com\n" +
+				"}\n\n",
+		},
+		{
+			name: "private CA",
+			acme: ACME{CA: "https://pebble:14000/dir", CARoot: root},
+			want: "{\n" +
+				"\tacme_ca https://pebble:14000/dir\n" +
+				"\tacme_ca_root /run/secrets/" + secretName("acme-ca-root", root) + "\n" +
+				"}\n\n",
+		},
+		{
+			name: "DNS challenge",
+			acme: ACME{DNS: &DNSProvider{
+				Name: "route53",
+				Credentials: map[string][]byte{
+					"secret_access_key": []byte("secret"),
+					"access_key_id":     []byte("key"),
+				},
+			}},
+			want: "{\n" +
+				"\tacme_dns route53 {\n" +
+				"\t\taccess_key_id {file./run/secrets/" + dnsSecretName("route53", "access_key_id", []byte("key")) + "}\n" +
+				"\t\tsecret_access_key {file./run/secrets/" + dnsSecretName("route53", "secret_access_key", []byte("secret")) + "}\n" +
+				"\t}\n" +
+				"}\n\n",
+		},
+		{
+			name: "DNS challenge without credentials",
+			acme: ACME{DNS: &DNSProvider{Name: "duckdns"}},
+			want: "{\n" +
+				"\tacme_dns duckdns\n" +
+				"}\n\n",
+		},
+		{
+			name: "on demand",
+			acme: ACME{Email: "ops@example.com", OnDemandAsk: "http://auth:8080/check"},
+			want: "{\n" +
+				"\temail ops@example.com\n" +
+				"\ton_demand_tls {\n" +
+				"\t\task http://auth:8080/check\n" +
+				"\t}\n" +
+				"}\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := generateGlobalOptions(tt.acme); got != tt.want {
+				t.Errorf("generateGlobalOptions() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateACME(t *testing.T) {
+	tests := []struct {
+		name    string
+		acme    ACME
+		wantErr bool
+	}{
+		{name: "defaults"},
+		{name: "email", acme: ACME{Email: "ops@example.com"}},
+		{name: "email without at", acme: ACME{Email: "ops.example.com"}, wantErr: true},
+		{name: "email with brace", acme: ACME{Email: "ops@example.com}"}, wantErr: true},
+		{name: "CA", acme: ACME{CA: "https://acme-staging-v02.api.letsencrypt.org/directory"}},
+		{name: "CA without scheme", acme: ACME{CA: "pebble:14000/dir"}, wantErr: true},
+		{name: "CA root", acme: ACME{CARoot: testCertificate(t)}},
+		{name: "CA root without certificate", acme: ACME{CARoot: []byte("not a certificate")}, wantErr: true},
+		{name: "on demand ask", acme: ACME{OnDemandAsk: "http://auth:8080/check"}},
+		{name: "on demand ask with space", acme: ACME{OnDemandAsk: "http://auth:8080/check me"}, wantErr: true},
+		{name: "DNS provider", acme: ACME{DNS: &DNSProvider{Name: "cloudflare", Credentials: map[string][]byte{"api_token": []byte("token")}}}},
+		{name: "invalid DNS provider", acme: ACME{DNS: &DNSProvider{Name: "cloudflare {"}}, wantErr: true},
+		{name: "invalid DNS credential", acme: ACME{DNS: &DNSProvider{Name: "cloudflare", Credentials: map[string][]byte{"api-token": []byte("token")}}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateACME(tt.acme)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateACME() = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// testCertificate returns a PEM encoded self-signed certificate.
+func testCertificate(t *testing.T) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Root"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
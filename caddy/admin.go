@@ -0,0 +1,226 @@
+package caddy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/d3witt/dockboy/sshexec"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/client"
+)
+
+// Caddy only accepts a few Host values on unix sockets, see
+// https://github.com/caddyserver/caddy/blob/master/admin.go
+const adminURL = "http://127.0.0.1"
+
+type adminClient struct {
+	http *http.Client
+}
+
+func newAdminClient(ctx context.Context, sshClient *sshexec.Client, remote *client.Client) (*adminClient, error) {
+	socket, err := adminSocketPath(ctx, remote)
+	if err != nil {
+		return nil, err
+	}
+
+	return &adminClient{
+		http: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					return sshClient.Dial("unix", socket)
+				},
+			},
+		},
+	}, nil
+}
+
+// adminSocketPath returns the host path of the admin socket of the newest
+// running Caddy task. Each task has its own socket, see caddyServiceSpec.
+func adminSocketPath(ctx context.Context, remote *client.Client) (string, error) {
+	task, err := runningTask(ctx, remote)
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(hostAdminDir, task.ID+".sock"), nil
+}
+
+// removeStaleSockets removes the admin sockets of Caddy tasks that no longer
+// run, e.g. the ones of tasks replaced by a rollout or killed before Caddy
+// could clean up.
+func removeStaleSockets(ctx context.Context, sshClient *sshexec.Client, remote *client.Client) error {
+	tasks, err := remote.TaskList(ctx, types.TaskListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("service", caddyServiceName),
+			filters.Arg("desired-state", "running"),
+		),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list tasks: %w", err)
+	}
+
+	files, err := sshexec.Command(sshClient, "ls", "-1", hostAdminDir).Output()
+	if err != nil {
+		return fmt.Errorf("failed to list %s: %w", hostAdminDir, err)
+	}
+
+	var stale []string
+	for _, name := range strings.Fields(files) {
+		if !strings.HasSuffix(name, ".sock") {
+			continue
+		}
+		id := strings.TrimSuffix(name, ".sock")
+		if !slices.ContainsFunc(tasks, func(task swarm.Task) bool { return task.ID == id }) {
+			stale = append(stale, path.Join(hostAdminDir, name))
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+
+	// The directory is only writable by root, see prepareAdminDir.
+	paths := strings.Join(stale, " ")
+	script := fmt.Sprintf("rm -f %[1]s 2>/dev/null || sudo -n rm -f %[1]s", paths)
+	if out, err := sshexec.Command(sshClient, script).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to remove stale admin sockets: %w\n%s", err, out)
+	}
+
+	return nil
+}
+
+// runningTask returns the newest running Caddy task.
+func runningTask(ctx context.Context, remote *client.Client) (*swarm.Task, error) {
+	tasks, err := remote.TaskList(ctx, types.TaskListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("service", caddyServiceName),
+			filters.Arg("desired-state", "running"),
+		),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tasks: %w", err)
+	}
+
+	var newest *swarm.Task
+	for i, task := range tasks {
+		if task.Status.State != swarm.TaskStateRunning {
+			continue
+		}
+		if newest == nil || task.CreatedAt.After(newest.CreatedAt) {
+			newest = &tasks[i]
+		}
+	}
+	if newest == nil {
+		return nil, fmt.Errorf("no running tasks found for Caddy service")
+	}
+
+	return newest, nil
+}
+
+func (a *adminClient) Close() {
+	a.http.CloseIdleConnections()
+}
+
+// adapt converts the Caddyfile to JSON without loading it, so syntax errors
+// are reported before anything changes. Modules are not provisioned, so
+// errors such as a missing certificate file only show up in load.
+func (a *adminClient) adapt(ctx context.Context, caddyfile string) error {
+	data, err := a.request(ctx, http.MethodPost, "/adapt", "text/caddyfile", strings.NewReader(caddyfile))
+	if err != nil {
+		slog.DebugContext(ctx, "Invalid Caddy config", "caddyfile", caddyfile)
+		return fmt.Errorf("invalid Caddy config: %w", err)
+	}
+
+	var result struct {
+		Warnings []struct {
+			Line      int    `json:"line"`
+			Directive string `json:"directive"`
+			Message   string `json:"message"`
+		} `json:"warnings"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("failed to decode Caddy response: %w", err)
+	}
+
+	for _, w := range result.Warnings {
+		slog.WarnContext(ctx, "Caddy config warning", "line", w.Line, "directive", w.Directive, "message", w.Message)
+	}
+
+	return nil
+}
+
+// load atomically replaces the running Caddy config. If Caddy rejects the
+// config, the previous one keeps running.
+func (a *adminClient) load(ctx context.Context, caddyfile string) error {
+	_, err := a.request(ctx, http.MethodPost, "/load", "text/caddyfile", strings.NewReader(caddyfile))
+	if err != nil {
+		slog.DebugContext(ctx, "Rejected Caddy config", "caddyfile", caddyfile)
+		return fmt.Errorf("failed to load Caddy config: %w", err)
+	}
+	return nil
+}
+
+func (a *adminClient) config(ctx context.Context) ([]byte, error) {
+	config, err := a.request(ctx, http.MethodGet, "/config/", "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Caddy config: %w", err)
+	}
+	return config, nil
+}
+
+func (a *adminClient) request(ctx context.Context, method, endpoint, contentType string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, adminURL+endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := a.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach Caddy admin API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Caddy response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, adminError(resp.StatusCode, data)
+	}
+
+	return data, nil
+}
+
+// LiveConfig returns the JSON config Caddy is currently running.
+func LiveConfig(ctx context.Context, sshClient *sshexec.Client, remote *client.Client) ([]byte, error) {
+	admin, err := newAdminClient(ctx, sshClient, remote)
+	if err != nil {
+		return nil, err
+	}
+	defer admin.Close()
+
+	return admin.config(ctx)
+}
+
+func adminError(status int, body []byte) error {
+	var apiErr struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &apiErr); err == nil && apiErr.Error != "" {
+		return fmt.Errorf("caddy: %s", apiErr.Error)
+	}
+	return fmt.Errorf("caddy: unexpected status %d: %s", status, strings.TrimSpace(string(body)))
+}
@@ -2,52 +2,317 @@ package caddy
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
-	"path/filepath"
-	"strings"
+	"time"
 
 	"github.com/d3witt/dockboy/dockerhelper"
 	"github.com/d3witt/dockboy/sshexec"
 	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/docker/docker/client"
-	"golang.org/x/crypto/ssh"
 )
 
 const (
-	caddyServiceName = "dockboy-caddy"
-	caddyImage       = "caddy:latest"
-	caddyDataVolume  = "caddy_data"
-	caddySitesVolume = "caddy_sites"
+	caddyServiceName  = "dockboy-caddy"
+	caddyDataVolume   = "caddy_data"
+	caddyConfigVolume = "caddy_config"
+	caddySpecLabel    = "dockboy.caddy.spec"
+	// caddySettingsLabel holds the hash of the settings the service runs
+	// with, see Settings.hash.
+	caddySettingsLabel = "dockboy.caddy.settings"
+
+	// The admin API listens on a unix socket in a directory shared with the
+	// host, so it is reachable over SSH but neither from the internet nor from
+	// apps on the Swarm networks.
+	hostAdminDir      = "/var/lib/dockboy/caddy"
+	containerAdminDir = "/run/dockboy"
+)
+
+// prepareAdminDir creates the admin socket directory, readable only by root
+// and the docker group, whose members have root access through Docker
+// anyway. This lets non-root users in the docker group reach the sockets.
+var prepareAdminDir = fmt.Sprintf(
+	`[ "$(stat -c %%G %[1]s 2>/dev/null)" = docker ] || install -d -m 0750 -g docker %[1]s 2>/dev/null || sudo -n install -d -m 0750 -g docker %[1]s`,
+	hostAdminDir,
 )
 
 type ProxyConfig struct {
-	Address    string
-	TargetPort int
+	Address     string
+	Aliases     []string
+	Path        string
+	StripPrefix bool
+	RedirectTo  string
+	TargetPort  int
+	TLS         TLS
+	Options     SiteOptions
+
+	LoadBalancing LoadBalancing
+}
+
+// Upstream is a service traffic is proxied to. Weights are only used when
+// traffic is split between several upstreams.
+type Upstream struct {
+	Service string
+	Weight  int
+}
+
+// DeployCaddyService creates the Caddy service, or rolls it out again when its
+// spec no longer matches, e.g. because certificates were added. The proxy is
+// shared by all apps on the machine, so an existing one is only deployed with
+// the settings it runs with. UpgradeCaddyService changes them.
+func DeployCaddyService(ctx context.Context, out io.Writer, sshClient *sshexec.Client, remote *client.Client, network string, settings Settings) error {
+	return deployCaddyService(ctx, out, sshClient, remote, network, settings, false)
+}
+
+// UpgradeCaddyService rolls the Caddy service out with new settings. It also
+// stores the global options of the Caddyfile.
+func UpgradeCaddyService(ctx context.Context, out io.Writer, sshClient *sshexec.Client, remote *client.Client, network string, settings Settings) error {
+	return deployCaddyService(ctx, out, sshClient, remote, network, settings, true)
+}
+
+func deployCaddyService(ctx context.Context, out io.Writer, sshClient *sshexec.Client, remote *client.Client, network string, settings Settings, upgrade bool) error {
+	if err := settings.validate(); err != nil {
+		return err
+	}
+
+	existing, err := dockerhelper.FindService(ctx, remote, caddyServiceName)
+	if err != nil {
+		return err
+	}
+
+	if existing != nil && !upgrade {
+		// Services deployed before the label was added are taken as is.
+		running := existing.Spec.Labels[caddySettingsLabel]
+		if running != "" && running != settings.hash() {
+			return fmt.Errorf("the proxy settings differ from the ones the proxy on the machine runs with. " +
+				"Use the same [proxy] settings in all apps on the machine, and run `dockboy proxy upgrade` to change them")
+		}
+	}
+
+	secrets, err := caddySecrets(ctx, remote, settings, true)
+	if err != nil {
+		return err
+	}
+
+	spec, err := caddyServiceSpec(network, settings, secrets)
+	if err != nil {
+		return err
+	}
+
+	upToDate := existing != nil && existing.Spec.Labels[caddySpecLabel] == spec.Labels[caddySpecLabel]
+	if !upToDate {
+		if out, err := sshexec.Command(sshClient, prepareAdminDir).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to create %s: %w\n%s", hostAdminDir, err, out)
+		}
+
+		// Apps that were not deployed since Caddy was managed through
+		// files have no stored site yet, and would lose their routes.
+		if existing != nil && usesLegacySites(existing) {
+			if err := importLegacySites(ctx, out, remote); err != nil {
+				return err
+			}
+		}
+
+		if existing != nil {
+			// Leaving host mode, the new task cannot publish the ports
+			// while the old one still binds them.
+			if hostMode(existing.Spec) && !hostMode(spec) {
+				update := *spec.UpdateConfig
+				update.Order = swarm.UpdateOrderStopFirst
+				spec.UpdateConfig = &update
+			}
+			if hostMode(existing.Spec) || hostMode(spec) {
+				warnDowntime(out)
+			}
+			fmt.Fprintf(out, "dockboy: updating Caddy service to %s...\n", settings.ImageRef())
+			_, err = remote.ServiceUpdate(ctx, existing.ID, existing.Version, spec, types.ServiceUpdateOptions{})
+			if err != nil {
+				return fmt.Errorf("failed to update Caddy service: %w", err)
+			}
+		} else {
+			_, err = remote.ServiceCreate(ctx, spec, types.ServiceCreateOptions{})
+			if err != nil {
+				return fmt.Errorf("failed to create Caddy service: %w", err)
+			}
+		}
+
+		if err := dockerhelper.WaitForService(ctx, out, remote, caddyServiceName); err != nil {
+			return fmt.Errorf("failed to wait for Caddy service to be running: %w", err)
+		}
+
+		if err := removeStaleSockets(ctx, sshClient, remote); err != nil {
+			slog.WarnContext(ctx, "Failed to remove stale Caddy admin sockets", "error", err)
+		}
+	}
+
+	// The ACME settings belong to the machine, so only the deploy creating
+	// the proxy and upgrades store them. The global options are only stored
+	// once the secrets they refer to are mounted.
+	var changed bool
+	if existing == nil || upgrade {
+		changed, err = saveGlobalOptions(ctx, remote, generateGlobalOptions(settings.ACME))
+		if err != nil {
+			return err
+		}
+	}
+	if upgrade {
+		if err := removeUnusedACMESecrets(ctx, remote, settings.ACME); err != nil {
+			return err
+		}
+	}
+
+	// Caddy restores the sites from its autosaved config, but versions that
+	// managed Caddy through files did not have one.
+	if changed || (existing != nil && !upToDate) {
+		return Reload(ctx, sshClient, remote)
+	}
+
+	return nil
+}
+
+// caddySecrets returns the secrets to mount into the Caddy service.
+func caddySecrets(ctx context.Context, remote *client.Client, settings Settings, create bool) ([]swarm.Secret, error) {
+	tls, err := tlsSecrets(ctx, remote)
+	if err != nil {
+		return nil, err
+	}
+
+	acme, err := acmeSecrets(ctx, remote, settings.ACME, create)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(tls, acme...), nil
+}
+
+// RestartCaddyService replaces the Caddy task. The new task starts before the
+// old one stops, unless the ports are published in host mode, see
+// caddyServiceSpec.
+func RestartCaddyService(ctx context.Context, out io.Writer, sshClient *sshexec.Client, remote *client.Client) error {
+	existing, err := dockerhelper.FindService(ctx, remote, caddyServiceName)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return fmt.Errorf("Caddy service not found")
+	}
+
+	spec := existing.Spec
+	spec.TaskTemplate.ForceUpdate++
+
+	if hostMode(spec) {
+		warnDowntime(out)
+	}
+	fmt.Fprintln(out, "dockboy: restarting Caddy service...")
+	_, err = remote.ServiceUpdate(ctx, existing.ID, existing.Version, spec, types.ServiceUpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to update Caddy service: %w", err)
+	}
+
+	if err := dockerhelper.WaitForService(ctx, out, remote, caddyServiceName); err != nil {
+		return err
+	}
+
+	if err := removeStaleSockets(ctx, sshClient, remote); err != nil {
+		slog.WarnContext(ctx, "Failed to remove stale Caddy admin sockets", "error", err)
+	}
+
+	return nil
+}
+
+// hostMode reports whether the Caddy service publishes its ports in host
+// mode, see caddyServiceSpec.
+func hostMode(spec swarm.ServiceSpec) bool {
+	if spec.EndpointSpec == nil {
+		return false
+	}
+	for _, port := range spec.EndpointSpec.Ports {
+		if port.PublishMode == swarm.PortConfigPublishModeHost {
+			return true
+		}
+	}
+	return false
+}
+
+func warnDowntime(out io.Writer) {
+	fmt.Fprintln(out, "dockboy: WARNING: the proxy publishes its ports in host mode, so the old Caddy task is stopped first. "+
+		"All sites on the machine are down until the new one runs.")
 }
 
-func DeployCaddyService(ctx context.Context, out io.Writer, remote *client.Client, network string) error {
-	services, err := remote.ServiceList(ctx, types.ServiceListOptions{
-		Filters: filters.NewArgs(filters.Arg("name", caddyServiceName)),
-	})
+// Info describes the running Caddy service and the sites it serves per app.
+type Info struct {
+	Image        string
+	DesiredImage string
+	UpToDate     bool
+	RunningTasks uint64
+	DesiredTasks uint64
+	Sites        map[string][]string
+}
+
+// Inspect returns nil if the Caddy service does not exist.
+func Inspect(ctx context.Context, remote *client.Client, network string, settings Settings) (*Info, error) {
+	existing, err := dockerhelper.FindService(ctx, remote, caddyServiceName)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, nil
+	}
+
+	secrets, err := caddySecrets(ctx, remote, settings, false)
 	if err != nil {
-		return fmt.Errorf("failed to list services: %w", err)
+		return nil, err
 	}
-	if len(services) > 0 {
-		return nil
+
+	spec, err := caddyServiceSpec(network, settings, secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := dockerhelper.ServiceStatus(ctx, remote, existing.ID)
+	if err != nil {
+		return nil, err
 	}
 
+	sites, err := loadSites(ctx, remote)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &Info{
+		Image:        existing.Spec.TaskTemplate.ContainerSpec.Image,
+		DesiredImage: settings.ImageRef(),
+		UpToDate:     existing.Spec.Labels[caddySpecLabel] == spec.Labels[caddySpecLabel],
+		RunningTasks: status.RunningTasks,
+		DesiredTasks: status.DesiredTasks,
+		Sites:        make(map[string][]string),
+	}
+	for _, s := range sites {
+		if s.Caddyfile != "" {
+			info.Sites[s.App] = append(info.Sites[s.App], "imported from "+legacySitesDir)
+		}
+		for _, config := range s.Configs {
+			info.Sites[s.App] = append(info.Sites[s.App], config.Address+config.Path)
+		}
+	}
+
+	return info, nil
+}
+
+func caddyServiceSpec(network string, settings Settings, secrets []swarm.Secret) (swarm.ServiceSpec, error) {
 	spec := swarm.ServiceSpec{
 		Annotations: swarm.Annotations{
 			Name: caddyServiceName,
 		},
 		TaskTemplate: swarm.TaskSpec{
 			ContainerSpec: &swarm.ContainerSpec{
-				Image: caddyImage,
+				Image: settings.ImageRef(),
 				Mounts: []mount.Mount{
 					{
 						Type:   mount.TypeVolume,
@@ -56,15 +321,26 @@ func DeployCaddyService(ctx context.Context, out io.Writer, remote *client.Clien
 					},
 					{
 						Type:   mount.TypeVolume,
-						Source: caddySitesVolume,
-						Target: "/etc/caddy/sites",
+						Source: caddyConfigVolume,
+						Target: "/config",
+					},
+					{
+						Type:   mount.TypeBind,
+						Source: hostAdminDir,
+						Target: containerAdminDir,
 					},
 				},
-				Command: []string{
-					"/bin/sh",
-					"-c",
-					"echo 'import sites/*.conf' > /etc/caddy/Caddyfile && caddy run --config /etc/caddy/Caddyfile --adapter caddyfile",
+				// Every task gets its own admin socket, so a stopping task
+				// never removes the socket of the new one. The socket is
+				// writable by everyone, the directory restricts access, see
+				// prepareAdminDir.
+				Env: []string{
+					fmt.Sprintf("CADDY_ADMIN=unix/%s/{{.Task.ID}}.sock|0666", containerAdminDir),
 				},
+				// Sites are loaded through the admin API and autosaved, so
+				// --resume restores them on restart.
+				Command: []string{"caddy", "run", "--resume"},
+				Secrets: secretReferences(secrets),
 			},
 			RestartPolicy: &swarm.RestartPolicy{
 				Condition: swarm.RestartPolicyConditionAny,
@@ -82,157 +358,200 @@ func DeployCaddyService(ctx context.Context, out io.Writer, remote *client.Clien
 				},
 			},
 		},
+		UpdateConfig: &swarm.UpdateConfig{
+			Parallelism:   1,
+			FailureAction: swarm.UpdateFailureActionRollback,
+			Monitor:       10 * time.Second,
+			Order:         swarm.UpdateOrderStartFirst,
+		},
 		EndpointSpec: &swarm.EndpointSpec{
 			Ports: []swarm.PortConfig{
 				{
 					Protocol:      swarm.PortConfigProtocolTCP,
 					TargetPort:    80,
 					PublishedPort: 80,
-					PublishMode:   swarm.PortConfigPublishModeIngress,
+					PublishMode:   settings.publishMode(),
 				},
 				{
 					Protocol:      swarm.PortConfigProtocolTCP,
 					TargetPort:    443,
 					PublishedPort: 443,
-					PublishMode:   swarm.PortConfigPublishModeIngress,
+					PublishMode:   settings.publishMode(),
 				},
 				{
 					Protocol:      swarm.PortConfigProtocolUDP,
 					TargetPort:    80,
 					PublishedPort: 80,
-					PublishMode:   swarm.PortConfigPublishModeIngress,
+					PublishMode:   settings.publishMode(),
 				},
 				{
 					Protocol:      swarm.PortConfigProtocolUDP,
 					TargetPort:    443,
 					PublishedPort: 443,
-					PublishMode:   swarm.PortConfigPublishModeIngress,
+					PublishMode:   settings.publishMode(),
 				},
 			},
 		},
 	}
 
-	_, err = remote.ServiceCreate(ctx, spec, types.ServiceCreateOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to create Caddy service: %w", err)
+	// The ingress network hides the client's address, which IP allowlists
+	// need. In host mode only one task can bind the ports, so the old task
+	// is stopped first and the proxy is down while the new one starts.
+	if settings.publishMode() == swarm.PortConfigPublishModeHost {
+		spec.UpdateConfig.Order = swarm.UpdateOrderStopFirst
 	}
 
-	if err := dockerhelper.WaitForService(ctx, out, remote, caddyServiceName); err != nil {
-		return fmt.Errorf("failed to wait for Caddy service to be running: %w", err)
+	// The hash of the spec detects drift, e.g. a new image or new ports,
+	// without comparing against the defaults Docker fills in.
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return spec, fmt.Errorf("failed to encode Caddy service spec: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	spec.Labels = map[string]string{
+		caddySpecLabel:     hex.EncodeToString(sum[:8]),
+		caddySettingsLabel: settings.hash(),
 	}
 
-	return nil
+	return spec, nil
 }
 
-func AddPublicConfig(ctx context.Context, sshClient *ssh.Client, remote *client.Client, clientID string, configs []ProxyConfig, upstream string) error {
-	caddyfileSnippet := generateCaddyfileContent(configs, upstream)
-	clientConfigPath := fmt.Sprintf("/etc/caddy/sites/%s.conf", clientID)
+func AddPublicConfig(ctx context.Context, sshClient *sshexec.Client, remote *client.Client, clientID string, configs []ProxyConfig, upstreams []Upstream) error {
+	for _, config := range configs {
+		if config.RedirectTo != "" && !config.Options.empty() {
+			return fmt.Errorf("options are not supported for %s, it redirects to %s", config.Address, config.RedirectTo)
+		}
+		if err := validateOptions(config.Options); err != nil {
+			return fmt.Errorf("invalid options for %s%s: %w", config.Address, config.Path, err)
+		}
+		if err := validateLoadBalancing(config.LoadBalancing); err != nil {
+			return fmt.Errorf("invalid load balancing for %s%s: %w", config.Address, config.Path, err)
+		}
+		if len(config.Options.AllowIPs) > 0 {
+			if err := checkClientAddress(ctx, remote); err != nil {
+				return fmt.Errorf("allow_ips of %s%s: %w", config.Address, config.Path, err)
+			}
+		}
+	}
 
-	if err := writeToCaddyContainer(ctx, sshClient, remote, clientConfigPath, caddyfileSnippet); err != nil {
-		return fmt.Errorf("failed to write client config: %w", err)
+	sites, err := loadSites(ctx, remote)
+	if err != nil {
+		return err
 	}
 
-	if err := executeInCaddyContainer(ctx, sshClient, remote, "caddy", "reload", "--config", "/etc/caddy/Caddyfile"); err != nil {
-		return fmt.Errorf("failed to reload Caddy: %w", err)
+	clientSite := site{
+		App:       clientID,
+		Configs:   configs,
+		Upstreams: upstreams,
 	}
 
-	return nil
-}
+	// Deploying during maintenance keeps the maintenance page up.
+	for _, existing := range sites {
+		if existing.App == clientID {
+			clientSite.Maintenance = existing.Maintenance
+		}
+	}
 
-func RemovePublicConfig(ctx context.Context, sshClient *ssh.Client, remote *client.Client, clientID string) error {
-	clientConfigPath := fmt.Sprintf("/etc/caddy/sites/%s.conf", clientID)
+	return applySite(ctx, sshClient, remote, sites, clientSite)
+}
 
-	if err := executeInCaddyContainer(ctx, sshClient, remote, "rm", "-f", clientConfigPath); err != nil {
-		slog.WarnContext(ctx, "Failed to remove client config", "clientID", clientID, "error", err)
+// applySite stores the new version of the app's site and loads it into
+// Caddy. Caddy keeps running the previous config if it rejects the new one.
+// The whole config is loaded rather than patching the app's routes, because
+// apps share site blocks when they route paths on the same domain.
+func applySite(ctx context.Context, sshClient *sshexec.Client, remote *client.Client, sites []site, s site) error {
+	caddyfile, err := generateCaddyfile(ctx, remote, replaceSite(sites, s.App, &s))
+	if err != nil {
+		return err
 	}
 
-	if err := executeInCaddyContainer(ctx, sshClient, remote, "caddy", "reload", "--config", "/etc/caddy/Caddyfile"); err != nil {
-		return fmt.Errorf("failed to reload Caddy: %w", err)
+	admin, err := newAdminClient(ctx, sshClient, remote)
+	if err != nil {
+		return err
 	}
+	defer admin.Close()
 
-	return nil
-}
+	if err := admin.adapt(ctx, caddyfile); err != nil {
+		return err
+	}
 
-func generateCaddyfileContent(configs []ProxyConfig, defaultUpstream string) string {
-	var content string
+	siteID, err := saveSite(ctx, remote, s)
+	if err != nil {
+		return err
+	}
 
-	for _, config := range configs {
-		if config.Address == "" {
-			continue
+	if err := admin.load(ctx, caddyfile); err != nil {
+		// Drop the new version of the site and keep the previous one for
+		// later reloads.
+		if rmErr := remote.ConfigRemove(ctx, siteID); rmErr != nil {
+			slog.WarnContext(ctx, "Failed to remove rejected site config", "clientID", s.App, "error", rmErr)
 		}
+		return err
+	}
 
-		upstream := defaultUpstream
-		if config.TargetPort != 0 {
-			upstream = fmt.Sprintf("%s:%d", defaultUpstream, config.TargetPort)
-		}
+	return removeSite(ctx, remote, s.App, siteID)
+}
 
-		content += fmt.Sprintf("%s {\n\treverse_proxy %s\n}\n\n", config.Address, upstream)
+func RemovePublicConfig(ctx context.Context, sshClient *sshexec.Client, remote *client.Client, clientID string) error {
+	sites, err := loadSites(ctx, remote)
+	if err != nil {
+		return err
 	}
 
-	return content
-}
+	caddyfile, err := generateCaddyfile(ctx, remote, replaceSite(sites, clientID, nil))
+	if err != nil {
+		return err
+	}
 
-func writeToCaddyContainer(ctx context.Context, sshClient *ssh.Client, remote *client.Client, filePath, content string) error {
-	escapedContent := escapeForShell(content)
+	admin, err := newAdminClient(ctx, sshClient, remote)
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
 
-	dir := filepath.Dir(filePath)
-	if err := executeInCaddyContainer(ctx, sshClient, remote, "mkdir", "-p", dir); err != nil {
-		return fmt.Errorf("failed to create directory for %s: %w", filePath, err)
+	if err := admin.adapt(ctx, caddyfile); err != nil {
+		return err
 	}
 
-	command := fmt.Sprintf("\"echo '%s' > %s\"", escapedContent, filePath)
-	if err := executeInCaddyContainer(ctx, sshClient, remote, "sh", "-c", command); err != nil {
-		return fmt.Errorf("failed to write content to %s: %w", filePath, err)
+	if err := admin.load(ctx, caddyfile); err != nil {
+		return err
 	}
 
-	return nil
+	return removeSite(ctx, remote, clientID, "")
 }
 
-func executeInCaddyContainer(ctx context.Context, sshClient *ssh.Client, remote *client.Client, cmd ...string) error {
-	containerID, err := getCaddyContainerID(ctx, remote)
+// Reload loads the stored sites of all apps into Caddy.
+func Reload(ctx context.Context, sshClient *sshexec.Client, remote *client.Client) error {
+	sites, err := loadSites(ctx, remote)
+	if err != nil {
+		return err
+	}
+
+	caddyfile, err := generateCaddyfile(ctx, remote, sites)
 	if err != nil {
 		return err
 	}
 
-	dockerCmd := append([]string{"docker", "exec", containerID}, cmd...)
-	if out, err := sshexec.Command(sshClient, dockerCmd[0], dockerCmd[1:]...).CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to execute command: %w\n%s", err, out)
+	admin, err := newAdminClient(ctx, sshClient, remote)
+	if err != nil {
+		return err
 	}
+	defer admin.Close()
 
-	return nil
+	return admin.load(ctx, caddyfile)
 }
 
-func getCaddyContainerID(ctx context.Context, remote *client.Client) (string, error) {
-	services, err := remote.ServiceList(ctx, types.ServiceListOptions{
-		Filters: filters.NewArgs(filters.Arg("name", caddyServiceName)),
-	})
+// generateCaddyfile renders the stored global options and the sites.
+func generateCaddyfile(ctx context.Context, remote *client.Client, sites []site) (string, error) {
+	global, err := loadGlobalOptions(ctx, remote)
 	if err != nil {
-		return "", fmt.Errorf("failed to list services: %w", err)
-	}
-	if len(services) == 0 {
-		return "", fmt.Errorf("Caddy service not found")
+		return "", err
 	}
 
-	tasks, err := remote.TaskList(ctx, types.TaskListOptions{
-		Filters: filters.NewArgs(
-			filters.Arg("service", services[0].ID),
-			filters.Arg("desired-state", "running"),
-		),
-	})
+	content, err := generateCaddyfileContent(sites)
 	if err != nil {
-		return "", fmt.Errorf("failed to list tasks: %w", err)
-	}
-	if len(tasks) == 0 {
-		return "", fmt.Errorf("no running tasks found for Caddy service")
+		return "", err
 	}
 
-	return tasks[0].Status.ContainerStatus.ContainerID, nil
-}
-
-func escapeForShell(s string) string {
-	// Escape backslashes first to prevent double escaping
-	s = strings.ReplaceAll(s, `\`, `\\`)
-	// Escape single quotes
-	s = strings.ReplaceAll(s, `'`, `'\''`)
-	return s
+	return global + content, nil
 }
@@ -0,0 +1,204 @@
+package caddy
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type route struct {
+	app         string
+	config      ProxyConfig
+	upstreams   []Upstream
+	maintenance *Maintenance
+}
+
+type host struct {
+	address string
+	aliases []string
+	tls     TLS
+	tlsApp  string
+	routes  []route
+}
+
+// generateCaddyfileContent renders one site block per address. Apps sharing
+// an address are merged into the same block and routed by path.
+func generateCaddyfileContent(sites []site) (string, error) {
+	hosts := make(map[string]*host)
+	var order []string
+	var imported string
+
+	for _, s := range sites {
+		if s.Caddyfile != "" {
+			imported += strings.TrimSpace(s.Caddyfile) + "\n\n"
+		}
+
+		for _, config := range s.Configs {
+			if config.Address == "" {
+				continue
+			}
+
+			h, ok := hosts[config.Address]
+			if !ok {
+				h = &host{address: config.Address}
+				hosts[config.Address] = h
+				order = append(order, config.Address)
+			}
+
+			for _, r := range h.routes {
+				if r.config.Path == config.Path {
+					return "", fmt.Errorf("address %s%s is already used by %s", config.Address, config.Path, r.app)
+				}
+			}
+
+			if !config.TLS.empty() {
+				if !h.tls.empty() && h.tls != config.TLS {
+					return "", fmt.Errorf("address %s has a different TLS config in %s", config.Address, h.tlsApp)
+				}
+				h.tls, h.tlsApp = config.TLS, s.App
+			}
+
+			for _, alias := range config.Aliases {
+				if !slices.Contains(h.aliases, alias) {
+					h.aliases = append(h.aliases, alias)
+				}
+			}
+
+			h.routes = append(h.routes, route{
+				app:         s.App,
+				config:      config,
+				upstreams:   s.Upstreams,
+				maintenance: s.Maintenance,
+			})
+		}
+	}
+
+	sort.Strings(order)
+
+	var content string
+	for _, address := range order {
+		content += generateSite(hosts[address])
+	}
+
+	return content + imported, nil
+}
+
+func generateSite(h *host) string {
+	addresses := strings.Join(append([]string{h.address}, h.aliases...), ", ")
+
+	var tls string
+	if !h.tls.empty() {
+		tls = h.tls.directive()
+	}
+
+	if len(h.routes) == 1 && h.routes[0].config.Path == "" {
+		return addresses + " {\n" + indent(tls+generateRoute(h.routes[0])) + "}\n\n"
+	}
+
+	// More specific paths first, the catch-all route last.
+	sort.SliceStable(h.routes, func(i, j int) bool {
+		return len(h.routes[i].config.Path) > len(h.routes[j].config.Path)
+	})
+
+	var body []string
+	for _, r := range h.routes {
+		var handle string
+		switch {
+		case r.config.Path == "":
+			handle = "handle"
+		case r.config.StripPrefix:
+			handle = "handle_path " + r.config.Path
+		default:
+			handle = "handle " + r.config.Path
+		}
+
+		body = append(body, handle+" {\n"+indent(generateRoute(r))+"}\n")
+	}
+
+	if tls != "" {
+		body = append([]string{tls}, body...)
+	}
+
+	return addresses + " {\n" + indent(strings.Join(body, "\n")) + "}\n\n"
+}
+
+func generateRoute(r route) string {
+	if r.config.RedirectTo != "" {
+		return fmt.Sprintf("redir %s{uri} permanent\n", redirectTarget(r.config.RedirectTo))
+	}
+
+	handler := generateReverseProxy(r)
+
+	if r.config.Options.empty() && r.maintenance == nil {
+		return handler
+	}
+
+	var body string
+	if r.maintenance != nil {
+		body += generateMaintenance(r.maintenance)
+	}
+	body += generateOptions(r.config.Options) + handler
+
+	return "route {\n" + indent(body) + "}\n"
+}
+
+// generateReverseProxy proxies to the service address, which Swarm balances
+// between the tasks. With load balancing settings Caddy proxies to the tasks
+// directly instead, so passive health checks take unhealthy tasks out of
+// rotation.
+func generateReverseProxy(r route) string {
+	port := r.config.TargetPort
+	lb := r.config.LoadBalancing
+
+	hosts := make([]string, len(r.upstreams))
+	weights := make([]string, len(r.upstreams))
+	for i, upstream := range r.upstreams {
+		hosts[i] = upstream.Service
+		if port != 0 {
+			hosts[i] = fmt.Sprintf("%s:%d", upstream.Service, port)
+		}
+		weights[i] = strconv.Itoa(upstream.Weight)
+	}
+
+	switch {
+	case len(r.upstreams) > 1:
+		// Canary traffic is split by weight, whatever the policy.
+		lb.Policy, lb.Cookie = "", ""
+		body := fmt.Sprintf("lb_policy weighted_round_robin %s\n", strings.Join(weights, " ")) + generateLoadBalancing(lb)
+		return fmt.Sprintf("reverse_proxy %s {\n%s}\n", strings.Join(hosts, " "), indent(body))
+	case lb.empty():
+		return fmt.Sprintf("reverse_proxy %s\n", strings.Join(hosts, " "))
+	}
+
+	if port == 0 {
+		port = 80
+	}
+	var service string
+	if len(r.upstreams) > 0 {
+		service = r.upstreams[0].Service
+	}
+	dynamic := fmt.Sprintf("dynamic a {\n\tname tasks.%s\n\tport %d\n\trefresh 5s\n}\n", service, port)
+
+	return "reverse_proxy {\n" + indent(dynamic+generateLoadBalancing(lb)) + "}\n"
+}
+
+// redirectTarget defaults to HTTPS when the redirect target has no scheme.
+func redirectTarget(target string) string {
+	target = strings.TrimSuffix(target, "/")
+	if !strings.Contains(target, "://") {
+		target = "https://" + target
+	}
+	return target
+}
+
+func indent(s string) string {
+	lines := strings.SplitAfter(s, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			lines[i] = "\t" + line
+		}
+	}
+	return strings.Join(lines, "")
+}
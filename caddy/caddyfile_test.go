@@ -0,0 +1,86 @@
+package caddy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGenerateReverseProxy(t *testing.T) {
+	tests := []struct {
+		name  string
+		route route
+		want  string
+	}{
+		{
+			name: "service address",
+			route: route{
+				config:    ProxyConfig{TargetPort: 3000},
+				upstreams: []Upstream{{Service: "web", Weight: 100}},
+			},
+			want: "reverse_proxy web:3000\n",
+		},
+		{
+			name: "tasks with passive health checks",
+			route: route{
+				config: ProxyConfig{
+					TargetPort: 3000,
+					LoadBalancing: LoadBalancing{
+						Policy:  "least_conn",
+						Retries: 2,
+						Passive: &PassiveCheck{MaxFails: 3, UnhealthyStatus: []string{"5xx"}},
+					},
+				},
+				upstreams: []Upstream{{Service: "web", Weight: 100}},
+			},
+			want: "reverse_proxy {\n" +
+				"\tdynamic a {\n" +
+				"\t\tname tasks.web\n" +
+				"\t\tport 3000\n" +
+				"\t\trefresh 5s\n" +
+				"\t}\n" +
+				"\tlb_policy least_conn\n" +
+				"\tlb_retries 2\n" +
+				"\tfail_duration 30s\n" +
+				"\tmax_fails 3\n" +
+				"\tunhealthy_status 5xx\n" +
+				"}\n",
+		},
+		{
+			name: "tasks on the default port",
+			route: route{
+				config:    ProxyConfig{LoadBalancing: LoadBalancing{TryDuration: 5 * time.Second}},
+				upstreams: []Upstream{{Service: "web", Weight: 100}},
+			},
+			want: "reverse_proxy {\n" +
+				"\tdynamic a {\n" +
+				"\t\tname tasks.web\n" +
+				"\t\tport 80\n" +
+				"\t\trefresh 5s\n" +
+				"\t}\n" +
+				"\tlb_try_duration 5s\n" +
+				"}\n",
+		},
+		{
+			name: "canary",
+			route: route{
+				config: ProxyConfig{
+					TargetPort:    3000,
+					LoadBalancing: LoadBalancing{Policy: "cookie", Cookie: "lb", Passive: &PassiveCheck{}},
+				},
+				upstreams: []Upstream{{Service: "web", Weight: 90}, {Service: "web-canary", Weight: 10}},
+			},
+			want: "reverse_proxy web:3000 web-canary:3000 {\n" +
+				"\tlb_policy weighted_round_robin 90 10\n" +
+				"\tfail_duration 30s\n" +
+				"}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := generateReverseProxy(tt.route); got != tt.want {
+				t.Errorf("generateReverseProxy() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
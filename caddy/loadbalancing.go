@@ -0,0 +1,107 @@
+package caddy
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// LoadBalancing configures how Caddy picks the task serving a request and how
+// it detects unhealthy tasks. Caddy finds the tasks through DNS, so it can
+// only check them passively. Active health checks are left to Docker, which
+// takes unhealthy tasks out of DNS.
+type LoadBalancing struct {
+	Policy      string        `json:"policy,omitempty"`
+	Cookie      string        `json:"cookie,omitempty"`
+	Retries     int           `json:"retries,omitempty"`
+	TryDuration time.Duration `json:"try_duration,omitempty"`
+
+	Passive *PassiveCheck `json:"passive,omitempty"`
+}
+
+// PassiveCheck marks a task as unhealthy based on the requests it serves.
+type PassiveCheck struct {
+	FailDuration     time.Duration `json:"fail_duration,omitempty"`
+	MaxFails         int           `json:"max_fails,omitempty"`
+	UnhealthyStatus  []string      `json:"unhealthy_status,omitempty"`
+	UnhealthyLatency time.Duration `json:"unhealthy_latency,omitempty"`
+}
+
+var lbPolicies = []string{"round_robin", "least_conn", "ip_hash", "cookie", "random", "first"}
+
+var statusPattern = regexp.MustCompile(`^[1-5]([0-9]{2}|xx)$`)
+
+func (lb LoadBalancing) empty() bool {
+	return lb.Policy == "" && lb.Retries == 0 && lb.TryDuration == 0 && lb.Passive == nil
+}
+
+func validateLoadBalancing(lb LoadBalancing) error {
+	if lb.Policy != "" && !slices.Contains(lbPolicies, lb.Policy) {
+		return fmt.Errorf("invalid load balancing policy: %s (must be one of %s)", lb.Policy, strings.Join(lbPolicies, ", "))
+	}
+	if lb.Cookie != "" && lb.Policy != "cookie" {
+		return fmt.Errorf("a cookie name requires the cookie load balancing policy")
+	}
+	if lb.Cookie != "" && !validHeaderName(lb.Cookie) {
+		return fmt.Errorf("invalid cookie name: %q", lb.Cookie)
+	}
+	if lb.Retries < 0 {
+		return fmt.Errorf("retries must not be negative")
+	}
+
+	if p := lb.Passive; p != nil {
+		if p.MaxFails < 0 {
+			return fmt.Errorf("max_fails must not be negative")
+		}
+		for _, status := range p.UnhealthyStatus {
+			if !statusPattern.MatchString(status) {
+				return fmt.Errorf("invalid unhealthy status: %s", status)
+			}
+		}
+	}
+
+	return nil
+}
+
+// generateLoadBalancing renders the reverse_proxy subdirectives.
+func generateLoadBalancing(lb LoadBalancing) string {
+	var lines []string
+
+	switch {
+	case lb.Policy == "cookie" && lb.Cookie != "":
+		lines = append(lines, "lb_policy cookie "+lb.Cookie)
+	case lb.Policy != "":
+		lines = append(lines, "lb_policy "+lb.Policy)
+	}
+	if lb.Retries > 0 {
+		lines = append(lines, fmt.Sprintf("lb_retries %d", lb.Retries))
+	}
+	if lb.TryDuration > 0 {
+		lines = append(lines, "lb_try_duration "+lb.TryDuration.String())
+	}
+
+	if p := lb.Passive; p != nil {
+		// Caddy only enables passive health checks with a fail duration.
+		failDuration := p.FailDuration
+		if failDuration == 0 {
+			failDuration = 30 * time.Second
+		}
+		lines = append(lines, "fail_duration "+failDuration.String())
+		if p.MaxFails > 0 {
+			lines = append(lines, fmt.Sprintf("max_fails %d", p.MaxFails))
+		}
+		if len(p.UnhealthyStatus) > 0 {
+			lines = append(lines, "unhealthy_status "+strings.Join(p.UnhealthyStatus, " "))
+		}
+		if p.UnhealthyLatency > 0 {
+			lines = append(lines, "unhealthy_latency "+p.UnhealthyLatency.String())
+		}
+	}
+
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
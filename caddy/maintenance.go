@@ -0,0 +1,93 @@
+package caddy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/d3witt/dockboy/dockerhelper"
+	"github.com/d3witt/dockboy/sshexec"
+	"github.com/docker/docker/client"
+)
+
+// Maintenance replaces the app's responses with a static page, except for
+// requests from AllowIPs.
+type Maintenance struct {
+	Message    string        `json:"message"`
+	RetryAfter time.Duration `json:"retry_after,omitempty"`
+	AllowIPs   []string      `json:"allow_ips,omitempty"`
+}
+
+// SetMaintenance turns maintenance mode of the app on, or off if m is nil.
+func SetMaintenance(ctx context.Context, sshClient *sshexec.Client, remote *client.Client, clientID string, m *Maintenance) error {
+	if m != nil {
+		for _, ip := range m.AllowIPs {
+			if !validIP(ip) {
+				return fmt.Errorf("invalid IP address or CIDR range: %s", ip)
+			}
+		}
+	}
+
+	if m != nil && len(m.AllowIPs) > 0 {
+		if err := checkClientAddress(ctx, remote); err != nil {
+			return err
+		}
+	}
+
+	sites, err := loadSites(ctx, remote)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range sites {
+		if s.App == clientID {
+			if s.Caddyfile != "" {
+				return fmt.Errorf("app %s still uses the Caddy config imported from %s, deploy it again first", clientID, legacySitesDir)
+			}
+			s.Maintenance = m
+			return applySite(ctx, sshClient, remote, sites, s)
+		}
+	}
+
+	return fmt.Errorf("app %s has no public access configured", clientID)
+}
+
+// checkClientAddress makes sure Caddy sees the client's address, which it
+// only does if the proxy publishes its ports in host mode, see
+// caddyServiceSpec. Otherwise IP allowlists would match the address of the
+// ingress network for every client.
+func checkClientAddress(ctx context.Context, remote *client.Client) error {
+	service, err := dockerhelper.FindService(ctx, remote, caddyServiceName)
+	if err != nil {
+		return err
+	}
+	if service == nil {
+		return fmt.Errorf("Caddy service not found")
+	}
+
+	if !hostMode(service.Spec) {
+		return fmt.Errorf("the proxy publishes its ports through the ingress network, which hides the client's address. " +
+			"Set publish_mode = \"host\" in the [proxy] section and run `dockboy proxy upgrade` first")
+	}
+
+	return nil
+}
+
+// generateMaintenance renders the maintenance page. It must come before the
+// handler in a route block.
+func generateMaintenance(m *Maintenance) string {
+	var b strings.Builder
+
+	matcher := ""
+	if len(m.AllowIPs) > 0 {
+		matcher = "@maintenance "
+		fmt.Fprintf(&b, "@maintenance not remote_ip %s\n", strings.Join(m.AllowIPs, " "))
+	}
+	if m.RetryAfter > 0 {
+		fmt.Fprintf(&b, "header %sRetry-After %s\n", matcher, quote(fmt.Sprintf("%d", int(m.RetryAfter.Seconds()))))
+	}
+	fmt.Fprintf(&b, "respond %s%s 503\n", matcher, quote(m.Message))
+
+	return b.String()
+}
@@ -0,0 +1,41 @@
+package caddy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGenerateMaintenance(t *testing.T) {
+	tests := []struct {
+		name        string
+		maintenance Maintenance
+		want        string
+	}{
+		{
+			name:        "message",
+			maintenance: Maintenance{Message: "Back soon"},
+			want:        "respond \"Back soon\" 503\n",
+		},
+		{
+			name:        "retry after",
+			maintenance: Maintenance{Message: "Back soon", RetryAfter: 5 * time.Minute},
+			want: "header Retry-After \"300\"\n" +
+				"respond \"Back soon\" 503\n",
+		},
+		{
+			name:        "allow IPs",
+			maintenance: Maintenance{Message: "Back soon", RetryAfter: time.Minute, AllowIPs: []string{"203.0.113.7", "10.0.0.0/8"}},
+			want: "@maintenance not remote_ip 203.0.113.7 10.0.0.0/8\n" +
+				"header @maintenance Retry-After \"60\"\n" +
+				"respond @maintenance \"Back soon\" 503\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := generateMaintenance(&tt.maintenance); got != tt.want {
+				t.Errorf("generateMaintenance() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package caddy
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/client"
+)
+
+const (
+	// Versions that managed Caddy through files kept a Caddyfile snippet per
+	// app in this volume.
+	legacySitesVolume = "caddy_sites"
+	legacySitesDir    = "/etc/caddy/sites"
+	// legacyMergedSites held the sites of all apps, which are in the store
+	// already.
+	legacyMergedSites = "_dockboy.conf"
+)
+
+// usesLegacySites reports whether the service still reads its sites from
+// files.
+func usesLegacySites(service *swarm.Service) bool {
+	for _, m := range service.Spec.TaskTemplate.ContainerSpec.Mounts {
+		if m.Source == legacySitesVolume {
+			return true
+		}
+	}
+	return false
+}
+
+// importLegacySites stores the per-app Caddyfile snippets of the running
+// Caddy task, so the sites of apps that were not deployed since are still
+// served once Caddy is configured through the admin API. The snippets are
+// used as is until the app is deployed again.
+func importLegacySites(ctx context.Context, out io.Writer, remote *client.Client) error {
+	task, err := runningTask(ctx, remote)
+	if err != nil {
+		return fmt.Errorf("failed to import sites from %s: %w", legacySitesDir, err)
+	}
+
+	reader, _, err := remote.CopyFromContainer(ctx, task.Status.ContainerStatus.ContainerID, legacySitesDir)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", legacySitesDir, err)
+	}
+	defer reader.Close()
+
+	sites, err := loadSites(ctx, remote)
+	if err != nil {
+		return err
+	}
+	stored := make(map[string]bool, len(sites))
+	for _, s := range sites {
+		stored[s.App] = true
+	}
+
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", legacySitesDir, err)
+		}
+
+		name := path.Base(header.Name)
+		if header.Typeflag != tar.TypeReg || !strings.HasSuffix(name, ".conf") || name == legacyMergedSites {
+			continue
+		}
+
+		app := strings.TrimSuffix(name, ".conf")
+		if stored[app] {
+			continue
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", header.Name, err)
+		}
+
+		if _, err := saveSite(ctx, remote, site{App: app, Caddyfile: string(data)}); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "dockboy: imported Caddy config of %s from %s\n", app, path.Join(legacySitesDir, name))
+	}
+
+	return nil
+}
@@ -0,0 +1,246 @@
+package caddy
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SiteOptions configure how Caddy serves a public address in addition to
+// proxying it.
+type SiteOptions struct {
+	// Headers are set on responses. A name starting with '-' removes the
+	// header instead.
+	Headers         map[string]string `json:"headers,omitempty"`
+	SecurityHeaders bool              `json:"security_headers,omitempty"`
+	Encode          []string          `json:"encode,omitempty"`
+	// BasicAuth maps user names to bcrypt password hashes.
+	BasicAuth map[string]string `json:"basic_auth,omitempty"`
+	AllowIPs  []string          `json:"allow_ips,omitempty"`
+	CORS      *CORS             `json:"cors,omitempty"`
+}
+
+type CORS struct {
+	Origins     []string      `json:"origins"`
+	Methods     []string      `json:"methods,omitempty"`
+	Headers     []string      `json:"headers,omitempty"`
+	Credentials bool          `json:"credentials,omitempty"`
+	MaxAge      time.Duration `json:"max_age,omitempty"`
+}
+
+var encodings = []string{"gzip", "zstd"}
+
+var securityHeaders = [][2]string{
+	{"Strict-Transport-Security", "max-age=31536000; includeSubDomains"},
+	{"X-Content-Type-Options", "nosniff"},
+	{"X-Frame-Options", "DENY"},
+	{"Referrer-Policy", "strict-origin-when-cross-origin"},
+	{"-Server", ""},
+}
+
+func (o SiteOptions) empty() bool {
+	return len(o.Headers) == 0 && !o.SecurityHeaders && len(o.Encode) == 0 &&
+		len(o.BasicAuth) == 0 && len(o.AllowIPs) == 0 && o.CORS == nil
+}
+
+// validateOptions rejects options that would produce an invalid or
+// misleading Caddy config.
+func validateOptions(o SiteOptions) error {
+	for name, value := range o.Headers {
+		if !validHeaderName(strings.TrimPrefix(name, "-")) {
+			return fmt.Errorf("invalid header name: %q", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("invalid value for header %s", name)
+		}
+	}
+
+	for _, encoding := range o.Encode {
+		if !slices.Contains(encodings, encoding) {
+			return fmt.Errorf("invalid encoding: %s (must be one of %s)", encoding, strings.Join(encodings, ", "))
+		}
+	}
+
+	for user, hash := range o.BasicAuth {
+		if user == "" || strings.ContainsAny(user, " \t\r\n\"{}") {
+			return fmt.Errorf("invalid basic auth user: %q", user)
+		}
+		if !strings.HasPrefix(hash, "$2") {
+			return fmt.Errorf("password of basic auth user %s is not a bcrypt hash", user)
+		}
+	}
+
+	for _, ip := range o.AllowIPs {
+		if !validIP(ip) {
+			return fmt.Errorf("invalid IP address or CIDR range: %s", ip)
+		}
+	}
+
+	if o.CORS != nil {
+		if len(o.CORS.Origins) == 0 {
+			return fmt.Errorf("CORS requires at least one origin")
+		}
+		for _, origin := range o.CORS.Origins {
+			if origin != "*" && !strings.Contains(origin, "://") {
+				return fmt.Errorf("invalid CORS origin: %s (must include the scheme)", origin)
+			}
+			if strings.ContainsAny(origin, " \t\r\n\"") {
+				return fmt.Errorf("invalid CORS origin: %q", origin)
+			}
+		}
+		if o.CORS.Credentials && slices.Contains(o.CORS.Origins, "*") {
+			return fmt.Errorf("CORS credentials cannot be allowed for any origin")
+		}
+		for _, method := range o.CORS.Methods {
+			if !validHeaderName(method) {
+				return fmt.Errorf("invalid CORS method: %q", method)
+			}
+		}
+		for _, header := range o.CORS.Headers {
+			if !validHeaderName(header) {
+				return fmt.Errorf("invalid CORS header: %q", header)
+			}
+		}
+	}
+
+	return nil
+}
+
+// generateOptions renders the options in the order they must be applied, so
+// they must be placed in a route block, which Caddy does not reorder. The IP
+// allowlist comes first, so other clients can't even try passwords, and CORS
+// preflight requests, which never carry credentials, are answered before
+// basic auth.
+func generateOptions(o SiteOptions) string {
+	var b strings.Builder
+
+	if len(o.AllowIPs) > 0 {
+		fmt.Fprintf(&b, "@denied not remote_ip %s\n", strings.Join(o.AllowIPs, " "))
+		b.WriteString("respond @denied 403\n")
+	}
+
+	headers := generateHeaders(o)
+	if headers != "" {
+		b.WriteString("header {\n" + indent("defer\n"+headers) + "}\n")
+	}
+
+	if c := o.CORS; c != nil {
+		b.WriteString(generateCORS(c))
+	}
+
+	if len(o.BasicAuth) > 0 {
+		users := make([]string, 0, len(o.BasicAuth))
+		for user := range o.BasicAuth {
+			users = append(users, user)
+		}
+		sort.Strings(users)
+
+		var body string
+		for _, user := range users {
+			body += fmt.Sprintf("%s %s\n", user, o.BasicAuth[user])
+		}
+		b.WriteString("basic_auth {\n" + indent(body) + "}\n")
+	}
+
+	if len(o.Encode) > 0 {
+		fmt.Fprintf(&b, "encode %s\n", strings.Join(o.Encode, " "))
+	}
+
+	return b.String()
+}
+
+func generateHeaders(o SiteOptions) string {
+	var lines []string
+	if o.SecurityHeaders {
+		for _, header := range securityHeaders {
+			if _, ok := o.Headers[header[0]]; ok {
+				continue
+			}
+			lines = append(lines, headerLine(header[0], header[1]))
+		}
+	}
+
+	names := make([]string, 0, len(o.Headers))
+	for name := range o.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		lines = append(lines, headerLine(name, o.Headers[name]))
+	}
+
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func generateCORS(c *CORS) string {
+	origins := strings.Join(c.Origins, " ")
+
+	allowOrigin := "{http.request.header.Origin}"
+	if slices.Contains(c.Origins, "*") {
+		allowOrigin = "*"
+	}
+
+	lines := []string{
+		headerLine("Access-Control-Allow-Origin", allowOrigin),
+		headerLine("Vary", "Origin"),
+	}
+	if len(c.Methods) > 0 {
+		lines = append(lines, headerLine("Access-Control-Allow-Methods", strings.Join(c.Methods, ", ")))
+	}
+	if len(c.Headers) > 0 {
+		lines = append(lines, headerLine("Access-Control-Allow-Headers", strings.Join(c.Headers, ", ")))
+	}
+	if c.Credentials {
+		lines = append(lines, headerLine("Access-Control-Allow-Credentials", "true"))
+	}
+	if c.MaxAge > 0 {
+		lines = append(lines, headerLine("Access-Control-Max-Age", fmt.Sprintf("%d", int(c.MaxAge.Seconds()))))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "@cors header Origin %s\n", origins)
+	b.WriteString("header @cors {\n" + indent("defer\n"+strings.Join(lines, "\n")+"\n") + "}\n")
+	b.WriteString("@preflight {\n" + indent(fmt.Sprintf("header Origin %s\nmethod OPTIONS\n", origins)) + "}\n")
+	b.WriteString("respond @preflight 204\n")
+
+	return b.String()
+}
+
+func headerLine(name, value string) string {
+	if strings.HasPrefix(name, "-") {
+		return name
+	}
+	return fmt.Sprintf("%s %s", name, quote(value))
+}
+
+func quote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+func validIP(ip string) bool {
+	if _, err := netip.ParsePrefix(ip); err == nil {
+		return true
+	}
+	_, err := netip.ParseAddr(ip)
+	return err == nil
+}
+
+// validHeaderName reports whether s is an HTTP token.
+func validHeaderName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r > 127 || r <= 32 || strings.ContainsRune("\"(),/:;<=>?@[\\]{}", r) {
+			return false
+		}
+	}
+	return true
+}
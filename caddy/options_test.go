@@ -0,0 +1,167 @@
+package caddy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGenerateOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options SiteOptions
+		want    string
+	}{
+		{
+			name: "empty",
+			want: "",
+		},
+		{
+			name:    "allow IPs",
+			options: SiteOptions{AllowIPs: []string{"203.0.113.0/24", "198.51.100.7"}},
+			want: "@denied not remote_ip 203.0.113.0/24 198.51.100.7\n" +
+				"respond @denied 403\n",
+		},
+		{
+			name:    "headers",
+			options: SiteOptions{Headers: map[string]string{"X-Frame-Options": "SAMEORIGIN", "-Server": "", "X-Quote": `a "b"`}},
+			want: "header {\n" +
+				"\tdefer\n" +
+				"\t-Server\n" +
+				"\tX-Frame-Options \"SAMEORIGIN\"\n" +
+				"\tX-Quote \"a \\\"b\\\"\"\n" +
+				"}\n",
+		},
+		{
+			name:    "security headers can be overridden",
+			options: SiteOptions{SecurityHeaders: true, Headers: map[string]string{"X-Frame-Options": "SAMEORIGIN"}},
+			want: "header {\n" +
+				"\tdefer\n" +
+				"\tStrict-Transport-Security \"max-age=31536000; includeSubDomains\"\n" +
+				"\tX-Content-Type-Options \"nosniff\"\n" +
+				"\tReferrer-Policy \"strict-origin-when-cross-origin\"\n" +
+				"\t-Server\n" +
+				"\tX-Frame-Options \"SAMEORIGIN\"\n" +
+				"}\n",
+		},
+		{
+			name:    "encode",
+			options: SiteOptions{Encode: []string{"zstd", "gzip"}},
+			want:    "encode zstd gzip\n",
+		},
+		{
+			name:    "basic auth",
+			options: SiteOptions{BasicAuth: map[string]string{"bob": "$2a$14$b", "alice": "$2a$14$a"}},
+			want: "basic_auth {\n" +
+				"\talice $2a$14$a\n" +
+				"\tbob $2a$14$b\n" +
+				"}\n",
+		},
+		{
+			name: "CORS",
+			options: SiteOptions{CORS: &CORS{
+				Origins:     []string{"https://app.example.com"},
+				Methods:     []string{"GET", "POST"},
+				Headers:     []string{"Authorization"},
+				Credentials: true,
+				MaxAge:      time.Hour,
+			}},
+			want: "@cors header Origin https://app.example.com\n" +
+				"header @cors {\n" +
+				"\tdefer\n" +
+				"\tAccess-Control-Allow-Origin \"{http.request.header.Origin}\"\n" +
+				"\tVary \"Origin\"\n" +
+				"\tAccess-Control-Allow-Methods \"GET, POST\"\n" +
+				"\tAccess-Control-Allow-Headers \"Authorization\"\n" +
+				"\tAccess-Control-Allow-Credentials \"true\"\n" +
+				"\tAccess-Control-Max-Age \"3600\"\n" +
+				"}\n" +
+				"@preflight {\n" +
+				"\theader Origin https://app.example.com\n" +
+				"\tmethod OPTIONS\n" +
+				"}\n" +
+				"respond @preflight 204\n",
+		},
+		{
+			name:    "CORS for any origin",
+			options: SiteOptions{CORS: &CORS{Origins: []string{"*"}}},
+			want: "@cors header Origin *\n" +
+				"header @cors {\n" +
+				"\tdefer\n" +
+				"\tAccess-Control-Allow-Origin \"*\"\n" +
+				"\tVary \"Origin\"\n" +
+				"}\n" +
+				"@preflight {\n" +
+				"\theader Origin *\n" +
+				"\tmethod OPTIONS\n" +
+				"}\n" +
+				"respond @preflight 204\n",
+		},
+		{
+			name: "order",
+			options: SiteOptions{
+				AllowIPs:  []string{"10.0.0.0/8"},
+				Headers:   map[string]string{"X-Robots-Tag": "noindex"},
+				CORS:      &CORS{Origins: []string{"*"}},
+				BasicAuth: map[string]string{"admin": "$2a$14$h"},
+				Encode:    []string{"gzip"},
+			},
+			want: "@denied not remote_ip 10.0.0.0/8\n" +
+				"respond @denied 403\n" +
+				"header {\n" +
+				"\tdefer\n" +
+				"\tX-Robots-Tag \"noindex\"\n" +
+				"}\n" +
+				"@cors header Origin *\n" +
+				"header @cors {\n" +
+				"\tdefer\n" +
+				"\tAccess-Control-Allow-Origin \"*\"\n" +
+				"\tVary \"Origin\"\n" +
+				"}\n" +
+				"@preflight {\n" +
+				"\theader Origin *\n" +
+				"\tmethod OPTIONS\n" +
+				"}\n" +
+				"respond @preflight 204\n" +
+				"basic_auth {\n" +
+				"\tadmin $2a$14$h\n" +
+				"}\n" +
+				"encode gzip\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := generateOptions(tt.options); got != tt.want {
+				t.Errorf("generateOptions() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options SiteOptions
+		wantErr bool
+	}{
+		{name: "empty"},
+		{name: "IP and range", options: SiteOptions{AllowIPs: []string{"203.0.113.7", "2001:db8::/32"}}},
+		{name: "invalid IP", options: SiteOptions{AllowIPs: []string{"203.0.113"}}, wantErr: true},
+		{name: "invalid header name", options: SiteOptions{Headers: map[string]string{"X Frame": "DENY"}}, wantErr: true},
+		{name: "header value with newline", options: SiteOptions{Headers: map[string]string{"X-Test": "a\nb"}}, wantErr: true},
+		{name: "invalid encoding", options: SiteOptions{Encode: []string{"br"}}, wantErr: true},
+		{name: "plain text password", options: SiteOptions{BasicAuth: map[string]string{"admin": "secret"}}, wantErr: true},
+		{name: "CORS without origins", options: SiteOptions{CORS: &CORS{}}, wantErr: true},
+		{name: "CORS origin without scheme", options: SiteOptions{CORS: &CORS{Origins: []string{"example.com"}}}, wantErr: true},
+		{name: "CORS credentials for any origin", options: SiteOptions{CORS: &CORS{Origins: []string{"*"}, Credentials: true}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateOptions(tt.options)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateOptions() = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package caddy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types/swarm"
+)
+
+const (
+	DefaultImage   = "caddy"
+	DefaultVersion = "2.8.4"
+	// MinVersion is the oldest Caddy that accepts the generated config, which
+	// uses basic_auth and lb_policy weighted_round_robin.
+	MinVersion = "2.8"
+
+	customImageName = "dockboy-caddy"
+)
+
+// Settings configure the Caddy service shared by all apps on the machine.
+type Settings struct {
+	Image   string
+	Version string
+	// PublishMode is how ports 80 and 443 are published, see
+	// caddyServiceSpec. The default is ingress.
+	PublishMode swarm.PortConfigPublishMode
+	// Modules are built into a custom image with xcaddy.
+	Modules []string
+	ACME    ACME
+}
+
+// ImageRef returns the image the Caddy service runs.
+func (s Settings) ImageRef() string {
+	if len(s.Modules) == 0 {
+		return s.baseImage()
+	}
+
+	// The tag changes with the base image and the modules, so a changed
+	// build is picked up as drift of the service spec.
+	modules := append([]string(nil), s.Modules...)
+	sort.Strings(modules)
+	sum := sha256.Sum256([]byte(s.baseImage() + "\n" + strings.Join(modules, "\n")))
+
+	return customImageName + ":" + s.version() + "-" + hex.EncodeToString(sum[:6])
+}
+
+// NeedsBuild reports whether the image has to be built before deploying.
+func (s Settings) NeedsBuild() bool {
+	return len(s.Modules) > 0
+}
+
+// Dockerfile returns the Dockerfile of the custom image. The official builder
+// image of the same version compiles Caddy, which is then copied into the
+// configured image.
+func (s Settings) Dockerfile() (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "FROM %s:%s-builder AS builder\n", DefaultImage, s.version())
+	b.WriteString("RUN xcaddy build")
+	for _, module := range s.Modules {
+		if module == "" || strings.ContainsAny(module, " \t\n\"'\\=") {
+			return "", fmt.Errorf("invalid Caddy module: %q", module)
+		}
+		fmt.Fprintf(&b, " \\\n    --with %s", module)
+	}
+	b.WriteString("\n\n")
+	fmt.Fprintf(&b, "FROM %s\n", s.baseImage())
+	b.WriteString("COPY --from=builder /usr/bin/caddy /usr/bin/caddy\n")
+
+	return b.String(), nil
+}
+
+func (s Settings) validate() error {
+	if _, tag := splitTag(s.Image); tag != "" && s.Version != "" && tag != s.Version {
+		return fmt.Errorf("proxy image %s and version %s do not match, set only one of them", s.Image, s.Version)
+	}
+	if !supportedVersion(s.version()) {
+		return fmt.Errorf("proxy version %s is not supported, Caddy %s or later is required", s.version(), MinVersion)
+	}
+	switch s.PublishMode {
+	case "", swarm.PortConfigPublishModeIngress, swarm.PortConfigPublishModeHost:
+	default:
+		return fmt.Errorf("invalid proxy publish mode: %s", s.PublishMode)
+	}
+	return validateACME(s.ACME)
+}
+
+// hash identifies the settings, so a proxy deployed with other settings is
+// detected. Credentials are covered through the names of their secrets.
+func (s Settings) hash() string {
+	sum := sha256.Sum256([]byte(s.ImageRef() + "\n" + string(s.publishMode()) + "\n" + generateGlobalOptions(s.ACME)))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (s Settings) publishMode() swarm.PortConfigPublishMode {
+	if s.PublishMode == "" {
+		return swarm.PortConfigPublishModeIngress
+	}
+	return s.PublishMode
+}
+
+func (s Settings) baseImage() string {
+	image := s.Image
+	if image == "" {
+		image = DefaultImage
+	}
+
+	// An image pinned by digest is used as is.
+	if strings.Contains(image, "@") {
+		return image
+	}
+
+	name, _ := splitTag(image)
+	return name + ":" + s.version()
+}
+
+func (s Settings) version() string {
+	if s.Version != "" {
+		return s.Version
+	}
+
+	if _, tag := splitTag(s.Image); tag != "" {
+		return tag
+	}
+
+	return DefaultVersion
+}
+
+// supportedVersion reports whether the version is MinVersion or later. Tags
+// that are not versions, such as latest, are accepted.
+func supportedVersion(version string) bool {
+	var major, minor, minMajor, minMinor int
+	if _, err := fmt.Sscanf(version, "%d.%d", &major, &minor); err != nil {
+		return true
+	}
+	fmt.Sscanf(MinVersion, "%d.%d", &minMajor, &minMinor)
+
+	return major > minMajor || major == minMajor && minor >= minMinor
+}
+
+// splitTag splits the tag off an image reference. A colon before the last
+// slash belongs to the registry port, e.g. registry:5000/caddy.
+func splitTag(image string) (name, tag string) {
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i:], "/") || strings.Contains(image, "@") {
+		return image, ""
+	}
+	return image[:i], image[i+1:]
+}
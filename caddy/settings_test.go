@@ -0,0 +1,105 @@
+package caddy
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/swarm"
+)
+
+func TestSettingsBaseImage(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings Settings
+		want     string
+	}{
+		{
+			name: "default",
+			want: "caddy:" + DefaultVersion,
+		},
+		{
+			name:     "version",
+			settings: Settings{Version: "2.9.1"},
+			want:     "caddy:2.9.1",
+		},
+		{
+			name:     "image with tag",
+			settings: Settings{Image: "caddy:2.9.1-alpine"},
+			want:     "caddy:2.9.1-alpine",
+		},
+		{
+			name:     "image with tag and same version",
+			settings: Settings{Image: "caddy:2.9.1", Version: "2.9.1"},
+			want:     "caddy:2.9.1",
+		},
+		{
+			name:     "registry with port",
+			settings: Settings{Image: "registry:5000/caddy"},
+			want:     "registry:5000/caddy:" + DefaultVersion,
+		},
+		{
+			name:     "registry with port and tag",
+			settings: Settings{Image: "registry:5000/caddy:2.9.1"},
+			want:     "registry:5000/caddy:2.9.1",
+		},
+		{
+			name:     "registry with port and version",
+			settings: Settings{Image: "registry:5000/caddy", Version: "2.9.1"},
+			want:     "registry:5000/caddy:2.9.1",
+		},
+		{
+			name:     "digest",
+			settings: Settings{Image: "caddy@sha256:0123456789abcdef"},
+			want:     "caddy@sha256:0123456789abcdef",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.settings.baseImage(); got != tt.want {
+				t.Errorf("baseImage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSettingsValidate(t *testing.T) {
+	if err := (Settings{Image: "caddy:2.9.1", Version: "2.8.4"}).validate(); err == nil {
+		t.Error("validate() accepted an image tag that differs from the version")
+	}
+	if err := (Settings{Image: "registry:5000/caddy", Version: "2.8.4"}).validate(); err != nil {
+		t.Errorf("validate() = %v, want nil", err)
+	}
+	for _, s := range []Settings{{Version: "2.7.6"}, {Image: "caddy:2.7.6-alpine"}, {Version: "1.0.4"}} {
+		if err := s.validate(); err == nil {
+			t.Errorf("validate() accepted version %s", s.version())
+		}
+	}
+	for _, s := range []Settings{{Version: "2.8"}, {Version: "2.10.0"}, {Version: "latest"}, {Image: "caddy:alpine"}} {
+		if err := s.validate(); err != nil {
+			t.Errorf("validate() = %v, want nil", err)
+		}
+	}
+	if err := (Settings{PublishMode: "bridge"}).validate(); err == nil {
+		t.Error("validate() accepted an invalid publish mode")
+	}
+}
+
+func TestSettingsHash(t *testing.T) {
+	base := Settings{}
+	if base.hash() != (Settings{Version: DefaultVersion}).hash() {
+		t.Error("hash() differs for equivalent settings")
+	}
+
+	changed := []Settings{
+		{Version: "2.9.1"},
+		{PublishMode: swarm.PortConfigPublishModeHost},
+		{Modules: []string{"github.com/caddy-dns/cloudflare"}},
+		{ACME: ACME{Email: "ops@example.com"}},
+		{ACME: ACME{DNS: &DNSProvider{Name: "cloudflare", Credentials: map[string][]byte{"api_token": []byte("secret")}}}},
+	}
+	for _, s := range changed {
+		if s.hash() == base.hash() {
+			t.Errorf("hash() of %+v equals the hash of the default settings", s)
+		}
+	}
+}
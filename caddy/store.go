@@ -0,0 +1,203 @@
+package caddy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/client"
+)
+
+const (
+	siteAppLabel       = "dockboy.caddy.app"
+	globalOptionsLabel = "dockboy.caddy.global"
+)
+
+// site is the public configuration owned by one app. Sites are stored as Swarm
+// configs so that the Caddy config for all apps on the machine can be
+// regenerated whenever one of them changes.
+type site struct {
+	App         string        `json:"app"`
+	Configs     []ProxyConfig `json:"configs"`
+	Upstreams   []Upstream    `json:"upstreams"`
+	Maintenance *Maintenance  `json:"maintenance,omitempty"`
+	// Caddyfile is a snippet imported from versions that managed Caddy
+	// through files, see importLegacySites. It is used instead of Configs.
+	Caddyfile string `json:"caddyfile,omitempty"`
+}
+
+// saveSite stores a new version of the app's site and returns the ID of the
+// Swarm config holding it. Older versions are kept until removeSite is called.
+func saveSite(ctx context.Context, remote *client.Client, s site) (string, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode site: %w", err)
+	}
+
+	resp, err := remote.ConfigCreate(ctx, swarm.ConfigSpec{
+		Annotations: swarm.Annotations{
+			Name:   fmt.Sprintf("dockboy-caddy-%s-%d", s.App, time.Now().UnixNano()),
+			Labels: map[string]string{siteAppLabel: s.App},
+		},
+		Data: data,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create site config: %w", err)
+	}
+
+	return resp.ID, nil
+}
+
+// loadSites returns the latest site of every app, sorted by app name.
+func loadSites(ctx context.Context, remote *client.Client) ([]site, error) {
+	configs, err := listSiteConfigs(ctx, remote, "")
+	if err != nil {
+		return nil, err
+	}
+
+	latest := make(map[string]swarm.Config)
+	for _, config := range configs {
+		app := config.Spec.Labels[siteAppLabel]
+		if current, ok := latest[app]; !ok || config.CreatedAt.After(current.CreatedAt) {
+			latest[app] = config
+		}
+	}
+
+	sites := make([]site, 0, len(latest))
+	for app, config := range latest {
+		var s site
+		if err := json.Unmarshal(config.Spec.Data, &s); err != nil {
+			return nil, fmt.Errorf("failed to decode site of %s: %w", app, err)
+		}
+		sites = append(sites, s)
+	}
+
+	sort.Slice(sites, func(i, j int) bool {
+		return sites[i].App < sites[j].App
+	})
+
+	return sites, nil
+}
+
+// removeSite removes all stored versions of the app's site except keepID.
+func removeSite(ctx context.Context, remote *client.Client, app, keepID string) error {
+	configs, err := listSiteConfigs(ctx, remote, app)
+	if err != nil {
+		return err
+	}
+
+	for _, config := range configs {
+		if config.ID == keepID {
+			continue
+		}
+		if err := remote.ConfigRemove(ctx, config.ID); err != nil {
+			return fmt.Errorf("failed to remove site config %s: %w", config.Spec.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func listSiteConfigs(ctx context.Context, remote *client.Client, app string) ([]swarm.Config, error) {
+	label := siteAppLabel
+	if app != "" {
+		label += "=" + app
+	}
+
+	configs, err := remote.ConfigList(ctx, types.ConfigListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", label)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list site configs: %w", err)
+	}
+
+	return configs, nil
+}
+
+// replaceSite returns sites with the app's site replaced by s, or without it
+// if s has no configs.
+func replaceSite(sites []site, app string, s *site) []site {
+	res := make([]site, 0, len(sites)+1)
+	for _, existing := range sites {
+		if existing.App != app {
+			res = append(res, existing)
+		}
+	}
+	if s != nil {
+		res = append(res, *s)
+	}
+	return res
+}
+
+// saveGlobalOptions stores the global options block of the Caddyfile and
+// reports whether it changed.
+func saveGlobalOptions(ctx context.Context, remote *client.Client, options string) (bool, error) {
+	configs, err := remote.ConfigList(ctx, types.ConfigListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", globalOptionsLabel)),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to list global options: %w", err)
+	}
+
+	current := latestConfig(configs)
+	if current != nil && string(current.Spec.Data) == options {
+		return false, nil
+	}
+	if current == nil && options == "" {
+		return false, nil
+	}
+
+	resp, err := remote.ConfigCreate(ctx, swarm.ConfigSpec{
+		Annotations: swarm.Annotations{
+			Name:   fmt.Sprintf("dockboy-caddy-global-%d", time.Now().UnixNano()),
+			Labels: map[string]string{globalOptionsLabel: "true"},
+		},
+		Data: []byte(options),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to create global options: %w", err)
+	}
+
+	for _, config := range configs {
+		if config.ID == resp.ID {
+			continue
+		}
+		if err := remote.ConfigRemove(ctx, config.ID); err != nil {
+			return false, fmt.Errorf("failed to remove global options %s: %w", config.Spec.Name, err)
+		}
+	}
+
+	return true, nil
+}
+
+// loadGlobalOptions returns the global options block of the Caddyfile.
+func loadGlobalOptions(ctx context.Context, remote *client.Client) (string, error) {
+	configs, err := remote.ConfigList(ctx, types.ConfigListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", globalOptionsLabel)),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list global options: %w", err)
+	}
+
+	current := latestConfig(configs)
+	if current == nil {
+		return "", nil
+	}
+
+	return string(current.Spec.Data), nil
+}
+
+func latestConfig(configs []swarm.Config) *swarm.Config {
+	var latest *swarm.Config
+	for i := range configs {
+		if latest == nil || configs[i].CreatedAt.After(latest.CreatedAt) {
+			latest = &configs[i]
+		}
+	}
+	return latest
+}
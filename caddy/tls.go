@@ -0,0 +1,363 @@
+package caddy
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/d3witt/dockboy/sshexec"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/client"
+)
+
+const (
+	// tlsSecretLabel marks the secrets mounted into the Caddy service. Its
+	// value is the app the certificate belongs to.
+	tlsSecretLabel        = "dockboy.caddy.tls"
+	tlsSecretDomainsLabel = "dockboy.caddy.tls.domains"
+	tlsSecretIssuerLabel  = "dockboy.caddy.tls.issuer"
+	tlsSecretExpiryLabel  = "dockboy.caddy.tls.expiry"
+
+	containerSecretsDir = "/run/secrets"
+	certificatesDir     = "/data/caddy/certificates"
+)
+
+// TLS configures the certificate of a site. By default Caddy obtains one
+// from a public ACME CA.
+type TLS struct {
+	Internal   bool   `json:"internal,omitempty"`
+	CertSecret string `json:"cert_secret,omitempty"`
+	KeySecret  string `json:"key_secret,omitempty"`
+	// OnDemand obtains certificates during the first TLS handshake for a
+	// domain, see ACME.OnDemandAsk.
+	OnDemand bool `json:"on_demand,omitempty"`
+}
+
+func (t TLS) empty() bool {
+	return !t.Internal && t.CertSecret == "" && !t.OnDemand
+}
+
+func (t TLS) directive() string {
+	var args string
+	switch {
+	case t.Internal:
+		args = " internal"
+	case t.CertSecret != "":
+		args = fmt.Sprintf(" %s %s", path.Join(containerSecretsDir, t.CertSecret), path.Join(containerSecretsDir, t.KeySecret))
+	}
+
+	if t.OnDemand {
+		return "tls" + args + " {\n\ton_demand\n}\n"
+	}
+	return "tls" + args + "\n"
+}
+
+// Certificate describes a certificate Caddy serves.
+type Certificate struct {
+	Domains []string
+	Issuer  string
+	Expires time.Time
+	// App is set for certificates uploaded by an app, and empty for the
+	// ones Caddy manages.
+	App string
+}
+
+// CreateCertificate stores a certificate and its key as Swarm secrets, which
+// DeployCaddyService mounts into the Caddy service. Secrets are named after
+// their content, so deploying the same certificate again reuses them.
+func CreateCertificate(ctx context.Context, remote *client.Client, app string, cert, key []byte) (TLS, error) {
+	if _, err := tls.X509KeyPair(cert, key); err != nil {
+		return TLS{}, fmt.Errorf("invalid certificate or key: %w", err)
+	}
+
+	parsed, err := parseCertificate(cert)
+	if err != nil {
+		return TLS{}, err
+	}
+
+	labels := map[string]string{
+		tlsSecretLabel:        app,
+		tlsSecretDomainsLabel: strings.Join(certificateDomains(parsed), ","),
+		tlsSecretIssuerLabel:  certificateIssuer(parsed),
+		tlsSecretExpiryLabel:  parsed.NotAfter.UTC().Format(time.RFC3339),
+	}
+
+	certSecret, err := createSecret(ctx, remote, secretName("tls-"+app+"-cert", cert), cert, tlsSecretLabel, labels)
+	if err != nil {
+		return TLS{}, err
+	}
+	// The key is only labelled with the app, so certs list shows the
+	// certificate once.
+	keySecret, err := createSecret(ctx, remote, secretName("tls-"+app+"-key", key), key, tlsSecretLabel, map[string]string{tlsSecretLabel: app})
+	if err != nil {
+		return TLS{}, err
+	}
+
+	return TLS{CertSecret: certSecret, KeySecret: keySecret}, nil
+}
+
+// secretName names secrets after their content, because secrets can't be
+// changed.
+func secretName(kind string, data []byte) string {
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf("dockboy-caddy-%s-%s", kind, hex.EncodeToString(sum[:6]))
+}
+
+// createSecret creates the secret unless it exists. label is the label that
+// marks the secret as mounted into the Caddy service.
+func createSecret(ctx context.Context, remote *client.Client, name string, data []byte, label string, labels map[string]string) (string, error) {
+	secrets, err := remote.SecretList(ctx, types.SecretListOptions{
+		Filters: filters.NewArgs(filters.Arg("name", name)),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list secrets: %w", err)
+	}
+	for _, secret := range secrets {
+		if secret.Spec.Name != name {
+			continue
+		}
+
+		// PruneCertificates may have unlabelled it without removing it.
+		if _, ok := secret.Spec.Labels[label]; !ok {
+			spec := secret.Spec
+			spec.Labels = labels
+			if err := remote.SecretUpdate(ctx, secret.ID, secret.Version, spec); err != nil {
+				return "", fmt.Errorf("failed to update secret %s: %w", name, err)
+			}
+		}
+		return name, nil
+	}
+
+	_, err = remote.SecretCreate(ctx, swarm.SecretSpec{
+		Annotations: swarm.Annotations{
+			Name:   name,
+			Labels: labels,
+		},
+		Data: data,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create secret %s: %w", name, err)
+	}
+
+	return name, nil
+}
+
+// tlsSecrets returns the secrets to mount into the Caddy service.
+func tlsSecrets(ctx context.Context, remote *client.Client) ([]swarm.Secret, error) {
+	secrets, err := remote.SecretList(ctx, types.SecretListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", tlsSecretLabel)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list TLS secrets: %w", err)
+	}
+
+	sort.Slice(secrets, func(i, j int) bool {
+		return secrets[i].Spec.Name < secrets[j].Spec.Name
+	})
+
+	return secrets, nil
+}
+
+func secretReferences(secrets []swarm.Secret) []*swarm.SecretReference {
+	refs := make([]*swarm.SecretReference, 0, len(secrets))
+	for _, secret := range secrets {
+		refs = append(refs, &swarm.SecretReference{
+			SecretID:   secret.ID,
+			SecretName: secret.Spec.Name,
+			File: &swarm.SecretReferenceFileTarget{
+				Name: secret.Spec.Name,
+				UID:  "0",
+				GID:  "0",
+				Mode: 0o400,
+			},
+		})
+	}
+	return refs
+}
+
+// PruneCertificates removes the certificates no site uses anymore. They are
+// unmounted from the Caddy service first, because Swarm does not remove
+// secrets in use.
+func PruneCertificates(ctx context.Context, out io.Writer, sshClient *sshexec.Client, remote *client.Client, network string, settings Settings) error {
+	sites, err := loadSites(ctx, remote)
+	if err != nil {
+		return err
+	}
+
+	used := make(map[string]bool)
+	for _, s := range sites {
+		for _, config := range s.Configs {
+			used[config.TLS.CertSecret] = true
+			used[config.TLS.KeySecret] = true
+		}
+	}
+
+	secrets, err := tlsSecrets(ctx, remote)
+	if err != nil {
+		return err
+	}
+
+	var unused []swarm.Secret
+	for _, secret := range secrets {
+		if used[secret.Spec.Name] {
+			continue
+		}
+
+		// Only labels can be changed on secrets.
+		spec := secret.Spec
+		spec.Labels = make(map[string]string)
+		for k, v := range secret.Spec.Labels {
+			if k != tlsSecretLabel {
+				spec.Labels[k] = v
+			}
+		}
+		if err := remote.SecretUpdate(ctx, secret.ID, secret.Version, spec); err != nil {
+			return fmt.Errorf("failed to update secret %s: %w", secret.Spec.Name, err)
+		}
+		unused = append(unused, secret)
+	}
+
+	if len(unused) == 0 {
+		return nil
+	}
+
+	if err := DeployCaddyService(ctx, out, sshClient, remote, network, settings); err != nil {
+		return err
+	}
+
+	for _, secret := range unused {
+		if err := remote.SecretRemove(ctx, secret.ID); err != nil {
+			slog.WarnContext(ctx, "Failed to remove TLS secret", "secret", secret.Spec.Name, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// ListCertificates returns the certificates Caddy manages, read from its
+// storage volume, and the ones uploaded by apps.
+func ListCertificates(ctx context.Context, remote *client.Client) ([]Certificate, error) {
+	task, err := runningTask(ctx, remote)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, _, err := remote.CopyFromContainer(ctx, task.Status.ContainerStatus.ContainerID, certificatesDir)
+	if err != nil {
+		if !client.IsErrNotFound(err) {
+			return nil, fmt.Errorf("failed to read certificates: %w", err)
+		}
+		// Caddy has not obtained any certificates yet.
+		reader = io.NopCloser(strings.NewReader(""))
+	}
+	defer reader.Close()
+
+	var certs []Certificate
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read certificates: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg || !strings.HasSuffix(header.Name, ".crt") {
+			continue
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", header.Name, err)
+		}
+
+		cert, err := parseCertificate(data)
+		if err != nil {
+			slog.WarnContext(ctx, "Skipping certificate", "file", header.Name, "error", err)
+			continue
+		}
+
+		certs = append(certs, Certificate{
+			Domains: certificateDomains(cert),
+			Issuer:  certificateIssuer(cert),
+			Expires: cert.NotAfter,
+		})
+	}
+
+	secrets, err := tlsSecrets(ctx, remote)
+	if err != nil {
+		return nil, err
+	}
+	for _, secret := range secrets {
+		labels := secret.Spec.Labels
+		if labels[tlsSecretExpiryLabel] == "" {
+			continue
+		}
+
+		expires, err := time.Parse(time.RFC3339, labels[tlsSecretExpiryLabel])
+		if err != nil {
+			return nil, fmt.Errorf("invalid expiry of secret %s: %w", secret.Spec.Name, err)
+		}
+
+		certs = append(certs, Certificate{
+			Domains: strings.Split(labels[tlsSecretDomainsLabel], ","),
+			Issuer:  labels[tlsSecretIssuerLabel],
+			Expires: expires,
+			App:     labels[tlsSecretLabel],
+		})
+	}
+
+	sort.SliceStable(certs, func(i, j int) bool {
+		return strings.Join(certs[i].Domains, ",") < strings.Join(certs[j].Domains, ",")
+	})
+
+	return certs, nil
+}
+
+// parseCertificate parses the first certificate of a PEM bundle, which is
+// the leaf certificate.
+func parseCertificate(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no PEM encoded certificate found")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	return cert, nil
+}
+
+func certificateDomains(cert *x509.Certificate) []string {
+	domains := append([]string(nil), cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		domains = append(domains, ip.String())
+	}
+	if len(domains) == 0 && cert.Subject.CommonName != "" {
+		domains = append(domains, cert.Subject.CommonName)
+	}
+	return domains
+}
+
+func certificateIssuer(cert *x509.Certificate) string {
+	if len(cert.Issuer.Organization) > 0 {
+		return cert.Issuer.Organization[0]
+	}
+	return cert.Issuer.CommonName
+}
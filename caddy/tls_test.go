@@ -0,0 +1,44 @@
+package caddy
+
+import "testing"
+
+func TestTLSDirective(t *testing.T) {
+	tests := []struct {
+		name string
+		tls  TLS
+		want string
+	}{
+		{
+			name: "internal",
+			tls:  TLS{Internal: true},
+			want: "tls internal\n",
+		},
+		{
+			name: "certificate",
+			tls:  TLS{CertSecret: "dockboy-caddy-cert-0123", KeySecret: "dockboy-caddy-key-4567"},
+			want: "tls /run/secrets/dockboy-caddy-cert-0123 /run/secrets/dockboy-caddy-key-4567\n",
+		},
+		{
+			name: "on demand",
+			tls:  TLS{OnDemand: true},
+			want: "tls {\n" +
+				"\ton_demand\n" +
+				"}\n",
+		},
+		{
+			name: "internal on demand",
+			tls:  TLS{Internal: true, OnDemand: true},
+			want: "tls internal {\n" +
+				"\ton_demand\n" +
+				"}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.tls.directive(); got != tt.want {
+				t.Errorf("directive() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
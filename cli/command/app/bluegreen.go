@@ -0,0 +1,74 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/d3witt/dockboy/caddy"
+	"github.com/d3witt/dockboy/cli/command"
+	"github.com/d3witt/dockboy/config"
+	"github.com/d3witt/dockboy/dockerhelper"
+	"github.com/d3witt/dockboy/sshexec"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/client"
+)
+
+// deployBlueGreen starts the new version next to the live one, switches the
+// Caddy site over once it is running and only then removes the old version.
+// If anything fails before the switch, the new service is removed and the live
+// one keeps serving. configurePublicAccess only fails before the switch.
+func deployBlueGreen(
+	ctx context.Context,
+	dockboyCli *command.Cli,
+	sshClient *sshexec.Client,
+	dockerClient *client.Client,
+	conf config.Config,
+	svc dockerhelper.ServiceConfig,
+	live *swarm.Service,
+) error {
+	svc.Name = nextColor(conf.Name, live)
+
+	stale, err := dockerhelper.FindService(ctx, dockerClient, svc.Name)
+	if err != nil {
+		return err
+	}
+	if stale != nil {
+		fmt.Fprintf(dockboyCli.Out, "dockboy: removing stale service '%s'...\n", svc.Name)
+		if err := dockerClient.ServiceRemove(ctx, stale.ID); err != nil {
+			return fmt.Errorf("failed to remove stale service %s: %w", svc.Name, err)
+		}
+	}
+
+	if err := dockerhelper.DeployService(ctx, dockboyCli.Out, dockerClient, svc); err != nil {
+		removeService(ctx, dockboyCli, dockerClient, svc.Name)
+		return err
+	}
+
+	if err := configurePublicAccess(ctx, dockboyCli, sshClient, dockerClient, conf, caddy.Upstream{Service: svc.Name}); err != nil {
+		removeService(ctx, dockboyCli, dockerClient, svc.Name)
+		return err
+	}
+
+	if live != nil {
+		fmt.Fprintf(dockboyCli.Out, "dockboy: removing previous service '%s'...\n", live.Spec.Name)
+		if err := dockerClient.ServiceRemove(ctx, live.ID); err != nil {
+			fmt.Fprintf(dockboyCli.Out, "dockboy: failed to remove previous service '%s': %v\n", live.Spec.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func nextColor(name string, live *swarm.Service) string {
+	if live != nil && live.Spec.Name == name {
+		return name + dockerhelper.GreenServiceSuffix
+	}
+	return name
+}
+
+func removeService(ctx context.Context, dockboyCli *command.Cli, dockerClient *client.Client, name string) {
+	fmt.Fprintf(dockboyCli.Out, "dockboy: removing service '%s'...\n", name)
+	if err := dockerClient.ServiceRemove(ctx, name); err != nil {
+		fmt.Fprintf(dockboyCli.Out, "dockboy: failed to remove service '%s': %v\n", name, err)
+	}
+}
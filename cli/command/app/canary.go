@@ -0,0 +1,180 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strconv"
+
+	"github.com/d3witt/dockboy/caddy"
+	"github.com/d3witt/dockboy/cli/command"
+	"github.com/d3witt/dockboy/config"
+	"github.com/d3witt/dockboy/dockerhelper"
+	"github.com/d3witt/dockboy/sshexec"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/client"
+	"github.com/urfave/cli/v2"
+)
+
+func NewCanaryCmd(dockboyCli *command.Cli) *cli.Command {
+	return &cli.Command{
+		Name:  "canary",
+		Usage: "Manage a canary release started with 'deploy --canary'",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "promote",
+				Usage: "Roll the canary version out to the app and stop the canary",
+				Action: func(ctx *cli.Context) error {
+					return runCanaryPromote(ctx.Context, dockboyCli)
+				},
+			},
+			{
+				Name:  "abort",
+				Usage: "Send all traffic back to the app and stop the canary",
+				Action: func(ctx *cli.Context) error {
+					return runCanaryAbort(ctx.Context, dockboyCli)
+				},
+			},
+		},
+	}
+}
+
+// deployCanary runs the new version as a separate service next to the live
+// one and splits the traffic between them by weight.
+func deployCanary(
+	ctx context.Context,
+	dockboyCli *command.Cli,
+	sshClient *sshexec.Client,
+	dockerClient *client.Client,
+	conf config.Config,
+	svc dockerhelper.ServiceConfig,
+	live *swarm.Service,
+	weight int,
+) error {
+	if live == nil {
+		return fmt.Errorf("%s is not deployed yet, deploy it before releasing a canary", conf.Name)
+	}
+
+	svc.Name = conf.Name + dockerhelper.CanaryServiceSuffix
+	// The published ports stay with the live service.
+	svc.Ports = nil
+	svc.Labels = maps.Clone(svc.Labels)
+	if svc.Labels == nil {
+		svc.Labels = make(map[string]string)
+	}
+	svc.Labels[dockerhelper.CanaryWeightLabel] = strconv.Itoa(weight)
+
+	if err := dockerhelper.DeployService(ctx, dockboyCli.Out, dockerClient, svc); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(dockboyCli.Out, "dockboy: sending %d%% of traffic to '%s'\n", weight, svc.Name)
+	return configurePublicAccess(ctx, dockboyCli, sshClient, dockerClient, conf,
+		caddy.Upstream{Service: live.Spec.Name, Weight: 100 - weight},
+		caddy.Upstream{Service: svc.Name, Weight: weight},
+	)
+}
+
+func runCanaryPromote(ctx context.Context, dockboyCli *command.Cli) error {
+	conf, err := dockboyCli.AppConfig()
+	if err != nil {
+		return err
+	}
+
+	sshClient, err := dockboyCli.DialMachine()
+	if err != nil {
+		return err
+	}
+	defer sshClient.Close()
+
+	dockerClient, err := dockerhelper.DialSSH(sshClient)
+	if err != nil {
+		return err
+	}
+	defer dockerClient.Close()
+
+	live, canary, err := findCanary(ctx, dockerClient, conf.Name)
+	if err != nil {
+		return err
+	}
+
+	spec := canary.Spec
+	spec.Name = live.Spec.Name
+	spec.EndpointSpec = live.Spec.EndpointSpec
+	spec.Labels = maps.Clone(canary.Spec.Labels)
+	delete(spec.Labels, dockerhelper.CanaryWeightLabel)
+
+	fmt.Fprintf(dockboyCli.Out, "dockboy: promoting '%s' to '%s'...\n", canary.Spec.Name, live.Spec.Name)
+	if _, err := dockerClient.ServiceUpdate(ctx, live.ID, live.Version, spec, types.ServiceUpdateOptions{}); err != nil {
+		return fmt.Errorf("service update failed: %w", err)
+	}
+
+	if err := dockerhelper.WaitForService(ctx, dockboyCli.Out, dockerClient, live.ID); err != nil {
+		return err
+	}
+
+	if err := configurePublicAccess(ctx, dockboyCli, sshClient, dockerClient, conf, caddy.Upstream{Service: live.Spec.Name}); err != nil {
+		return err
+	}
+
+	removeService(ctx, dockboyCli, dockerClient, canary.Spec.Name)
+
+	fmt.Fprintln(dockboyCli.Out, conf.Name)
+
+	return nil
+}
+
+func runCanaryAbort(ctx context.Context, dockboyCli *command.Cli) error {
+	conf, err := dockboyCli.AppConfig()
+	if err != nil {
+		return err
+	}
+
+	sshClient, err := dockboyCli.DialMachine()
+	if err != nil {
+		return err
+	}
+	defer sshClient.Close()
+
+	dockerClient, err := dockerhelper.DialSSH(sshClient)
+	if err != nil {
+		return err
+	}
+	defer dockerClient.Close()
+
+	live, canary, err := findCanary(ctx, dockerClient, conf.Name)
+	if err != nil {
+		return err
+	}
+
+	if err := configurePublicAccess(ctx, dockboyCli, sshClient, dockerClient, conf, caddy.Upstream{Service: live.Spec.Name}); err != nil {
+		return err
+	}
+
+	removeService(ctx, dockboyCli, dockerClient, canary.Spec.Name)
+
+	fmt.Fprintln(dockboyCli.Out, conf.Name)
+
+	return nil
+}
+
+func findCanary(ctx context.Context, dockerClient *client.Client, name string) (*swarm.Service, *swarm.Service, error) {
+	canary, err := dockerhelper.FindService(ctx, dockerClient, name+dockerhelper.CanaryServiceSuffix)
+	if err != nil {
+		return nil, nil, err
+	}
+	if canary == nil {
+		return nil, nil, fmt.Errorf("no canary release of %s in progress", name)
+	}
+
+	live, err := dockerhelper.FindAppService(ctx, dockerClient, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if live == nil {
+		return nil, nil, fmt.Errorf("service %s not found", name)
+	}
+
+	return live, canary, nil
+}
@@ -3,48 +3,119 @@ package app
 import (
 	"context"
 	"fmt"
-	"io"
 	"log/slog"
 	"net"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/d3witt/dockboy/caddy"
 	"github.com/d3witt/dockboy/cli/command"
+	"github.com/d3witt/dockboy/config"
 	"github.com/d3witt/dockboy/dockerhelper"
+	"github.com/d3witt/dockboy/sshexec"
 	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/docker/docker/client"
 	"github.com/urfave/cli/v2"
-	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	strategyRolling   = "rolling"
+	strategyBlueGreen = "blue-green"
 )
 
 func NewDeployCmd(dockboyCli *command.Cli) *cli.Command {
 	return &cli.Command{
 		Name:  "deploy",
 		Usage: "Deploy the app to the Swarm",
+		Flags: []cli.Flag{
+			&cli.IntFlag{
+				Name:  "canary",
+				Usage: "Release the new version as a canary receiving the given percentage of traffic",
+			},
+		},
 		Action: func(ctx *cli.Context) error {
-			return runDeploy(ctx.Context, dockboyCli)
+			return runDeploy(ctx.Context, dockboyCli, ctx.Int("canary"))
 		},
 	}
 }
 
-func runDeploy(ctx context.Context, dockboyCli *command.Cli) error {
+func runDeploy(ctx context.Context, dockboyCli *command.Cli, canaryWeight int) error {
 	conf, err := dockboyCli.AppConfig()
 	if err != nil {
 		return err
 	}
 
+	if canaryWeight < 0 || canaryWeight >= 100 {
+		return fmt.Errorf("invalid canary weight: %d (must be between 1 and 99)", canaryWeight)
+	}
+	if canaryWeight > 0 && len(conf.Public) == 0 {
+		return fmt.Errorf("canary releases require public access to be configured")
+	}
+	for _, public := range conf.Public {
+		// Without the ask endpoint, anyone pointing a domain at the server
+		// could make Caddy obtain certificates for it.
+		if public.TLS.OnDemand && conf.Proxy.ACME.OnDemandAsk == "" {
+			return fmt.Errorf("tls.on_demand of %s requires proxy.acme.on_demand_ask", public.Address)
+		}
+	}
+
+	// The config is checked before preparing the machine, which installs
+	// Docker and deploys the proxy.
+	if s := conf.Deploy.Strategy; s != "" && s != strategyRolling && s != strategyBlueGreen {
+		return fmt.Errorf("invalid deploy strategy: %s", s)
+	}
+
+	mode, err := parseMode(conf.Mode, conf.Replicas)
+	if err != nil {
+		return err
+	}
+
+	ports, err := parsePorts(conf.Ports, mode)
+	if err != nil {
+		return err
+	}
+	if len(ports) > 0 && conf.Deploy.Strategy == strategyBlueGreen {
+		return fmt.Errorf("published ports are not supported with the blue-green strategy, both colors would publish them")
+	}
+	for _, port := range ports {
+		if port.PublishMode == swarm.PortConfigPublishModeHost && conf.Deploy.Order == swarm.UpdateOrderStartFirst {
+			return fmt.Errorf("port %d is published in host mode, which requires the stop-first deploy order", port.PublishedPort)
+		}
+	}
+
+	updateConfig, rollbackConfig, err := parseUpdateConfigs(conf.Deploy)
+	if err != nil {
+		return err
+	}
+
+	restartPolicy, err := parseRestartPolicy(conf.Deploy.RestartPolicy)
+	if err != nil {
+		return err
+	}
+
+	var stopGracePeriod *time.Duration
+	if conf.StopGracePeriod > 0 {
+		d := time.Duration(conf.StopGracePeriod)
+		stopGracePeriod = &d
+	}
+
+	secrets, err := command.ParseSecrets(conf.Secrets)
+	if err != nil {
+		return err
+	}
+
 	sshClient, err := dockboyCli.DialMachine()
 	if err != nil {
 		return err
 	}
 	defer sshClient.Close()
 
-	if err := prepare(ctx, dockboyCli, sshClient); err != nil {
+	if err := checkDockerInstalled(dockboyCli, sshClient); err != nil {
 		return err
 	}
 
@@ -54,19 +125,19 @@ func runDeploy(ctx context.Context, dockboyCli *command.Cli) error {
 	}
 	defer dockerClient.Close()
 
-	replicas := conf.Replicas
-	if replicas == 0 {
-		replicas = 1
+	if err := prepare(ctx, dockboyCli, sshClient, dockerClient, conf); err != nil {
+		return err
 	}
 
-	networks := []string{dockerhelper.DockboyInternalNetwork}
-	if len(conf.Public.Address) > 0 {
-		networks = append(networks, dockerhelper.DockboyPublicNetwork)
+	// The app's own services may publish the ports already.
+	if err := dockerhelper.CheckPortConflicts(ctx, dockerClient, ports,
+		conf.Name, conf.Name+dockerhelper.GreenServiceSuffix, conf.Name+dockerhelper.CanaryServiceSuffix); err != nil {
+		return err
 	}
 
-	secrets, err := parseSecrets(conf.Secrets)
-	if err != nil {
-		return err
+	networks := []string{dockerhelper.DockboyInternalNetwork}
+	if len(conf.Public) > 0 {
+		networks = append(networks, dockerhelper.DockboyPublicNetwork)
 	}
 
 	var healthCheck *container.HealthConfig
@@ -81,31 +152,69 @@ func runDeploy(ctx context.Context, dockboyCli *command.Cli) error {
 	}
 
 	mounts := parseVolumes(conf.Volumes)
+	resources := parseResources(conf.Resources)
 
-	if err := sendImage(ctx, dockboyCli, dockerClient, conf.Image); err != nil {
+	if err := dockerhelper.SendImage(ctx, dockboyCli.Out, dockerClient, conf.Image); err != nil {
 		return err
 	}
 
-	order := conf.Deploy.Order
-	if order == "" {
-		order = swarm.UpdateOrderStopFirst
+	svc := dockerhelper.ServiceConfig{
+		Name:            conf.Name,
+		Image:           conf.Image,
+		Entrypoint:      conf.Entrypoint,
+		Command:         conf.Command,
+		User:            conf.User,
+		WorkingDir:      conf.WorkingDir,
+		Hostname:        conf.Hostname,
+		Mode:            mode,
+		Networks:        networks,
+		Env:             conf.Env,
+		Labels:          conf.Label,
+		Secrets:         secrets,
+		Healthcheck:     healthCheck,
+		Mounts:          mounts,
+		Ports:           ports,
+		Resources:       resources,
+		RestartPolicy:   restartPolicy,
+		StopSignal:      conf.StopSignal,
+		StopGracePeriod: stopGracePeriod,
+		UpdateConfig:    updateConfig,
+		RollbackConfig:  rollbackConfig,
 	}
 
-	if err := dockerhelper.DeployService(ctx, dockboyCli.Out, dockerClient, conf.Name, conf.Image, replicas, networks, conf.Env, conf.Label, secrets, healthCheck, mounts, order); err != nil {
+	live, err := dockerhelper.FindAppService(ctx, dockerClient, conf.Name)
+	if err != nil {
 		return err
 	}
 
-	if len(conf.Public.Address) > 0 {
-		publicConfig := []caddy.ProxyConfig{
-			{
-				Address:    conf.Public.Address,
-				TargetPort: conf.Public.TargetPort,
-			},
+	canary, err := dockerhelper.FindService(ctx, dockerClient, conf.Name+dockerhelper.CanaryServiceSuffix)
+	if err != nil {
+		return err
+	}
+
+	if canaryWeight > 0 {
+		if err := deployCanary(ctx, dockboyCli, sshClient, dockerClient, conf, svc, live, canaryWeight); err != nil {
+			return err
+		}
+	} else if canary != nil {
+		return fmt.Errorf("a canary release of %s is in progress, run 'dockboy canary promote' or 'dockboy canary abort' first", conf.Name)
+	} else if conf.Deploy.Strategy == strategyBlueGreen {
+		if err := deployBlueGreen(ctx, dockboyCli, sshClient, dockerClient, conf, svc, live); err != nil {
+			return err
+		}
+	} else {
+		// Keep updating the current color if the app used the blue-green
+		// strategy before.
+		if live != nil {
+			svc.Name = live.Spec.Name
 		}
 
-		fmt.Fprintf(dockboyCli.Out, "dockboy: configuring public access for %s\n", conf.Public.Address)
-		if err := caddy.AddPublicConfig(ctx, sshClient, dockerClient, conf.Name, publicConfig, conf.Name); err != nil {
-			return fmt.Errorf("failed to configure public access: %w", err)
+		if err := dockerhelper.DeployService(ctx, dockboyCli.Out, dockerClient, svc); err != nil {
+			return err
+		}
+
+		if err := configurePublicAccess(ctx, dockboyCli, sshClient, dockerClient, conf, caddy.Upstream{Service: svc.Name}); err != nil {
+			return err
 		}
 	}
 
@@ -114,17 +223,105 @@ func runDeploy(ctx context.Context, dockboyCli *command.Cli) error {
 	return nil
 }
 
-func prepare(ctx context.Context, dockboyCli *command.Cli, sshClient *ssh.Client) error {
-	if err := checkDockerInstalled(dockboyCli, sshClient); err != nil {
-		return err
+func configurePublicAccess(ctx context.Context, dockboyCli *command.Cli, sshClient *sshexec.Client, dockerClient *client.Client, conf config.Config, upstreams ...caddy.Upstream) error {
+	if len(conf.Public) == 0 {
+		return nil
 	}
 
-	dockerClient, err := dockerhelper.DialSSH(sshClient)
+	var customCerts bool
+	publicConfig := make([]caddy.ProxyConfig, 0, len(conf.Public))
+	addresses := make([]string, 0, len(conf.Public))
+	for _, public := range conf.Public {
+		if public.Address == "" {
+			return fmt.Errorf("public address is required")
+		}
+		if public.Path != "" && !strings.HasPrefix(public.Path, "/") {
+			return fmt.Errorf("public path must start with '/': %s", public.Path)
+		}
+		if public.StripPrefix && public.Path == "" {
+			return fmt.Errorf("strip_prefix requires a public path")
+		}
+
+		options, err := parsePublicOptions(public.Options)
+		if err != nil {
+			return err
+		}
+
+		loadBalancing := parseLoadBalancing(public.LoadBalancing)
+
+		tls, err := parseTLS(ctx, dockerClient, conf.Name, public.TLS)
+		if err != nil {
+			return err
+		}
+		if tls.CertSecret != "" {
+			customCerts = true
+		}
+
+		publicConfig = append(publicConfig, caddy.ProxyConfig{
+			Address:     public.Address,
+			Aliases:     public.Aliases,
+			Path:        public.Path,
+			StripPrefix: public.StripPrefix,
+			RedirectTo:  public.RedirectTo,
+			TargetPort:  public.TargetPort,
+			TLS:         tls,
+			Options:     options,
+
+			LoadBalancing: loadBalancing,
+		})
+		addresses = append(addresses, public.Address+public.Path)
+	}
+
+	fmt.Fprintf(dockboyCli.Out, "dockboy: configuring public access for %s\n", strings.Join(addresses, ", "))
+	settings, err := command.ProxySettings(conf)
 	if err != nil {
 		return err
 	}
-	defer dockerClient.Close()
+	if customCerts {
+		// Mount new certificates into Caddy before the config refers to them.
+		if err := caddy.DeployCaddyService(ctx, dockboyCli.Out, sshClient, dockerClient, dockerhelper.DockboyPublicNetwork, settings); err != nil {
+			return err
+		}
+	}
+
+	if err := caddy.AddPublicConfig(ctx, sshClient, dockerClient, conf.Name, publicConfig, upstreams); err != nil {
+		return fmt.Errorf("failed to configure public access: %w", err)
+	}
+
+	// Caddy serves the new config already, so failing to clean up must not
+	// make callers undo the deploy.
+	if err := caddy.PruneCertificates(ctx, dockboyCli.Out, sshClient, dockerClient, dockerhelper.DockboyPublicNetwork, settings); err != nil {
+		fmt.Fprintf(dockboyCli.Out, "dockboy: failed to remove unused certificates: %v\n", err)
+	}
+
+	return nil
+}
+
+// parseTLS uploads the certificate and key files, if configured.
+func parseTLS(ctx context.Context, dockerClient *client.Client, app string, conf config.PublicTLSConfig) (caddy.TLS, error) {
+	if conf.Cert == "" && conf.Key == "" {
+		return caddy.TLS{Internal: conf.Internal, OnDemand: conf.OnDemand}, nil
+	}
+	if conf.Internal || conf.OnDemand {
+		return caddy.TLS{}, fmt.Errorf("tls.internal and tls.on_demand cannot be combined with a certificate")
+	}
+	if conf.Cert == "" || conf.Key == "" {
+		return caddy.TLS{}, fmt.Errorf("tls requires both cert and key")
+	}
+
+	cert, err := os.ReadFile(conf.Cert)
+	if err != nil {
+		return caddy.TLS{}, fmt.Errorf("failed to read certificate: %w", err)
+	}
+	key, err := os.ReadFile(conf.Key)
+	if err != nil {
+		return caddy.TLS{}, fmt.Errorf("failed to read key: %w", err)
+	}
+
+	return caddy.CreateCertificate(ctx, dockerClient, app, cert, key)
+}
 
+func prepare(ctx context.Context, dockboyCli *command.Cli, sshClient *sshexec.Client, dockerClient *client.Client, conf config.Config) error {
 	inactive, err := dockerhelper.IsSwarmInactive(ctx, dockerClient)
 	if err != nil {
 		return fmt.Errorf("could not check Swarm status on host %s: %w", sshClient.RemoteAddr().String(), err)
@@ -149,86 +346,309 @@ func prepare(ctx context.Context, dockboyCli *command.Cli, sshClient *ssh.Client
 		return err
 	}
 
+	if err := command.PrepareProxyImage(ctx, dockboyCli.Out, dockerClient, conf); err != nil {
+		return fmt.Errorf("failed to prepare Caddy image: %w", err)
+	}
+
 	fmt.Fprintln(dockboyCli.Out, "dockboy: preparing Caddy service...")
-	return caddy.DeployCaddyService(ctx, dockboyCli.Out, dockerClient, dockerhelper.DockboyPublicNetwork)
+	settings, err := command.ProxySettings(conf)
+	if err != nil {
+		return err
+	}
+
+	return caddy.DeployCaddyService(ctx, dockboyCli.Out, sshClient, dockerClient, dockerhelper.DockboyPublicNetwork, settings)
 }
 
-func checkDockerInstalled(dockboyCli *command.Cli, client *ssh.Client) error {
+func checkDockerInstalled(dockboyCli *command.Cli, client *sshexec.Client) error {
 	if !dockerhelper.IsDockerInstalled(client) {
 		fmt.Fprintf(dockboyCli.Out, "dockboy: Docker is not installed on host %s. Installing...\n", client.RemoteAddr().String())
 		if err := dockerhelper.InstallDocker(client); err != nil {
 			return fmt.Errorf("could not install Docker on host %s: %w", client.RemoteAddr().String(), err)
 		}
 	}
+
+	added, err := dockerhelper.EnsureDockerAccess(client)
+	if err != nil {
+		return fmt.Errorf("could not access Docker on host %s: %w", client.RemoteAddr().String(), err)
+	}
+	if added {
+		fmt.Fprintln(dockboyCli.Out, "dockboy: added the user to the docker group")
+	}
+
 	return nil
 }
 
-func sendImage(ctx context.Context, dockboyCli *command.Cli, remote *client.Client, imageName string) error {
-	fmt.Fprintln(dockboyCli.Out, "dockboy: sending image to remote host...")
+// parsePublicOptions hashes basic auth passwords that are not bcrypt hashes
+// yet. Caddy validates the rest of the options.
+func parsePublicOptions(conf config.PublicOptionsConfig) (caddy.SiteOptions, error) {
+	options := caddy.SiteOptions{
+		Headers:         conf.Headers,
+		SecurityHeaders: conf.SecurityHeaders,
+		Encode:          conf.Encode,
+		AllowIPs:        conf.AllowIPs,
+	}
 
-	local, err := client.NewClientWithOpts(client.FromEnv)
-	if err != nil {
-		return fmt.Errorf("failed to create local Docker client: %w", err)
+	if len(conf.BasicAuth) > 0 {
+		options.BasicAuth = make(map[string]string, len(conf.BasicAuth))
+		for user, password := range conf.BasicAuth {
+			if password == "" {
+				return options, fmt.Errorf("basic auth password for user %s is empty", user)
+			}
+			if strings.HasPrefix(password, "$2") {
+				options.BasicAuth[user] = password
+				continue
+			}
+
+			hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+			if err != nil {
+				return options, fmt.Errorf("failed to hash basic auth password for user %s: %w", user, err)
+			}
+			options.BasicAuth[user] = string(hash)
+		}
 	}
-	defer local.Close()
 
-	inspect, _, err := local.ImageInspectWithRaw(ctx, imageName)
-	if err != nil {
-		return fmt.Errorf("failed to inspect image on local client: %w", err)
+	cors := conf.CORS
+	if len(cors.Origins) > 0 || len(cors.Methods) > 0 || len(cors.Headers) > 0 || cors.Credentials || cors.MaxAge != 0 {
+		options.CORS = &caddy.CORS{
+			Origins:     cors.Origins,
+			Methods:     cors.Methods,
+			Headers:     cors.Headers,
+			Credentials: cors.Credentials,
+			MaxAge:      time.Duration(cors.MaxAge),
+		}
 	}
 
-	remoteImages, err := remote.ImageList(ctx, image.ListOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to list images on remote client: %w", err)
+	return options, nil
+}
+
+func parseLoadBalancing(conf config.LoadBalancingConfig) caddy.LoadBalancing {
+	lb := caddy.LoadBalancing{
+		Policy:      conf.Policy,
+		Cookie:      conf.Cookie,
+		Retries:     conf.Retries,
+		TryDuration: time.Duration(conf.TryDuration),
 	}
 
-	for _, img := range remoteImages {
-		if img.ID == inspect.ID {
-			return nil
+	passive := conf.Passive
+	if passive.FailDuration != 0 || passive.MaxFails != 0 || len(passive.UnhealthyStatus) > 0 || passive.UnhealthyLatency != 0 {
+		lb.Passive = &caddy.PassiveCheck{
+			FailDuration:     time.Duration(passive.FailDuration),
+			MaxFails:         passive.MaxFails,
+			UnhealthyStatus:  passive.UnhealthyStatus,
+			UnhealthyLatency: time.Duration(passive.UnhealthyLatency),
 		}
 	}
 
-	reader, err := local.ImageSave(ctx, []string{imageName})
-	if err != nil {
-		return fmt.Errorf("failed to save image on source: %w", err)
+	return lb
+}
+
+func parseResources(conf config.ResourcesConfig) *swarm.ResourceRequirements {
+	var resources swarm.ResourceRequirements
+
+	if conf.CPUs > 0 || conf.Memory > 0 || conf.PidsLimit > 0 {
+		resources.Limits = &swarm.Limit{
+			NanoCPUs:    int64(conf.CPUs * 1e9),
+			MemoryBytes: int64(conf.Memory),
+			Pids:        conf.PidsLimit,
+		}
 	}
-	defer reader.Close()
 
-	resp, err := remote.ImageLoad(ctx, reader, true)
-	if err != nil {
-		return fmt.Errorf("failed to load image on remote client: %w", err)
+	if conf.Reservation.CPUs > 0 || conf.Reservation.Memory > 0 {
+		resources.Reservations = &swarm.Resources{
+			NanoCPUs:    int64(conf.Reservation.CPUs * 1e9),
+			MemoryBytes: int64(conf.Reservation.Memory),
+		}
 	}
-	defer resp.Body.Close()
 
-	_, err = io.Copy(io.Discard, resp.Body)
-	if err != nil {
-		return fmt.Errorf("error reading response from remote client: %w", err)
+	if resources.Limits == nil && resources.Reservations == nil {
+		return nil
+	}
+
+	return &resources
+}
+
+func parseUpdateConfigs(conf config.DeployConfig) (*swarm.UpdateConfig, *swarm.UpdateConfig, error) {
+	order := conf.Order
+	if order == "" {
+		order = swarm.UpdateOrderStopFirst
+	}
+
+	update := &swarm.UpdateConfig{
+		Parallelism:     conf.Parallelism,
+		Delay:           time.Duration(conf.Delay),
+		FailureAction:   conf.FailureAction,
+		Monitor:         time.Duration(conf.Monitor),
+		MaxFailureRatio: conf.MaxFailureRatio,
+		Order:           order,
+	}
+	if update.Parallelism == 0 {
+		update.Parallelism = 1
+	}
+	if update.FailureAction == "" {
+		update.FailureAction = swarm.UpdateFailureActionRollback
+	}
+	if update.Monitor == 0 {
+		update.Monitor = 10 * time.Second
+	}
+
+	rollback := &swarm.UpdateConfig{
+		Parallelism:     conf.Rollback.Parallelism,
+		Delay:           time.Duration(conf.Rollback.Delay),
+		FailureAction:   conf.Rollback.FailureAction,
+		Monitor:         time.Duration(conf.Rollback.Monitor),
+		MaxFailureRatio: conf.Rollback.MaxFailureRatio,
+		Order:           order,
+	}
+	if rollback.Parallelism == 0 {
+		rollback.Parallelism = 1
+	}
+	if rollback.FailureAction == "" {
+		rollback.FailureAction = swarm.UpdateFailureActionPause
+	}
+	if rollback.Monitor == 0 {
+		rollback.Monitor = 10 * time.Second
+	}
+
+	if err := validateUpdateConfig(update, swarm.UpdateFailureActionPause, swarm.UpdateFailureActionContinue, swarm.UpdateFailureActionRollback); err != nil {
+		return nil, nil, fmt.Errorf("invalid update config: %w", err)
+	}
+	if err := validateUpdateConfig(rollback, swarm.UpdateFailureActionPause, swarm.UpdateFailureActionContinue); err != nil {
+		return nil, nil, fmt.Errorf("invalid rollback config: %w", err)
+	}
+
+	return update, rollback, nil
+}
+
+func validateUpdateConfig(cfg *swarm.UpdateConfig, failureActions ...string) error {
+	if cfg.Order != swarm.UpdateOrderStartFirst && cfg.Order != swarm.UpdateOrderStopFirst {
+		return fmt.Errorf("invalid order: %s", cfg.Order)
+	}
+
+	if !slices.Contains(failureActions, cfg.FailureAction) {
+		return fmt.Errorf("invalid failure action: %s (must be one of %s)", cfg.FailureAction, strings.Join(failureActions, ", "))
+	}
+
+	if cfg.MaxFailureRatio < 0 || cfg.MaxFailureRatio > 1 {
+		return fmt.Errorf("invalid max failure ratio: %v (must be between 0 and 1)", cfg.MaxFailureRatio)
 	}
 
 	return nil
 }
 
-func parseSecrets(secrets map[string]string) (map[string][]byte, error) {
-	res := make(map[string][]byte)
-	for key, value := range secrets {
-		if value == "" {
-			continue
+func parseRestartPolicy(conf config.RestartPolicyConfig) (*swarm.RestartPolicy, error) {
+	if conf == (config.RestartPolicyConfig{}) {
+		return nil, nil
+	}
+
+	condition := swarm.RestartPolicyCondition(conf.Condition)
+	switch condition {
+	case "":
+		condition = swarm.RestartPolicyConditionAny
+	case swarm.RestartPolicyConditionNone, swarm.RestartPolicyConditionOnFailure, swarm.RestartPolicyConditionAny:
+	default:
+		return nil, fmt.Errorf("invalid restart condition: %s", conf.Condition)
+	}
+
+	policy := &swarm.RestartPolicy{
+		Condition: condition,
+	}
+	if conf.Delay > 0 {
+		delay := time.Duration(conf.Delay)
+		policy.Delay = &delay
+	}
+	if conf.MaxAttempts > 0 {
+		maxAttempts := conf.MaxAttempts
+		policy.MaxAttempts = &maxAttempts
+	}
+	if conf.Window > 0 {
+		window := time.Duration(conf.Window)
+		policy.Window = &window
+	}
+
+	return policy, nil
+}
+
+func parsePorts(ports []config.PortConfig, mode swarm.ServiceMode) ([]swarm.PortConfig, error) {
+	res := make([]swarm.PortConfig, 0, len(ports))
+	for _, p := range ports {
+		if p.Published == 0 || p.Published > 65535 {
+			return nil, fmt.Errorf("invalid published port: %d", p.Published)
+		}
+		if p.Published == 80 || p.Published == 443 {
+			return nil, fmt.Errorf("port %d is used by the Caddy proxy, configure public access instead", p.Published)
+		}
+
+		target := p.Target
+		if target == 0 {
+			target = p.Published
+		}
+		if target > 65535 {
+			return nil, fmt.Errorf("invalid target port: %d", target)
+		}
+
+		protocol := swarm.PortConfigProtocol(p.Protocol)
+		switch protocol {
+		case "":
+			protocol = swarm.PortConfigProtocolTCP
+		case swarm.PortConfigProtocolTCP, swarm.PortConfigProtocolUDP, swarm.PortConfigProtocolSCTP:
+		default:
+			return nil, fmt.Errorf("invalid protocol for port %d: %s", p.Published, p.Protocol)
 		}
 
-		if strings.HasSuffix(key, "_file") {
-			content, err := os.ReadFile(value)
-			if err != nil {
-				return res, fmt.Errorf("failed to read secret file %s: %w", value, err)
+		publishMode := swarm.PortConfigPublishMode(p.Mode)
+		switch publishMode {
+		case "":
+			publishMode = swarm.PortConfigPublishModeIngress
+		case swarm.PortConfigPublishModeIngress:
+		case swarm.PortConfigPublishModeHost:
+			// Only one task can bind the port on the single server.
+			if mode.Replicated != nil && *mode.Replicated.Replicas > 1 {
+				return nil, fmt.Errorf("port %d is published in host mode, which allows only one replica", p.Published)
 			}
-			res[strings.TrimSuffix(key, "_file")] = content
-		} else {
-			res[key] = []byte(value)
+		default:
+			return nil, fmt.Errorf("invalid mode for port %d: %s", p.Published, p.Mode)
 		}
+
+		for _, existing := range res {
+			if existing.PublishedPort == p.Published && existing.Protocol == protocol {
+				return nil, fmt.Errorf("port %d/%s is published twice", p.Published, protocol)
+			}
+		}
+
+		res = append(res, swarm.PortConfig{
+			Protocol:      protocol,
+			TargetPort:    target,
+			PublishedPort: p.Published,
+			PublishMode:   publishMode,
+		})
 	}
 
 	return res, nil
 }
 
+func parseMode(mode string, replicas uint64) (swarm.ServiceMode, error) {
+	switch mode {
+	case "", "replicated":
+		if replicas == 0 {
+			replicas = 1
+		}
+		return swarm.ServiceMode{
+			Replicated: &swarm.ReplicatedService{
+				Replicas: &replicas,
+			},
+		}, nil
+	case "global":
+		if replicas != 0 {
+			return swarm.ServiceMode{}, fmt.Errorf("replicas cannot be set for global mode")
+		}
+		return swarm.ServiceMode{
+			Global: &swarm.GlobalService{},
+		}, nil
+	default:
+		return swarm.ServiceMode{}, fmt.Errorf("invalid mode: %s", mode)
+	}
+}
+
 func parseVolumes(volumes map[string]string) []mount.Mount {
 	res := make([]mount.Mount, 0, len(volumes))
 	for source, target := range volumes {
@@ -56,9 +56,24 @@ func runDestroy(ctx context.Context, dockboyCli *command.Cli, yes bool) error {
 	}
 	defer dockerClient.Close()
 
-	fmt.Fprintf(dockboyCli.Out, "dockboy: removing service %s...\n", conf.Name)
-	if err := dockerClient.ServiceRemove(ctx, conf.Name); err != nil {
-		return fmt.Errorf("failed to remove service %s: %w", conf.Name, err)
+	services, err := dockerhelper.FindAppServices(ctx, dockerClient, conf.Name)
+	if err != nil {
+		return err
+	}
+
+	canary, err := dockerhelper.FindService(ctx, dockerClient, conf.Name+dockerhelper.CanaryServiceSuffix)
+	if err != nil {
+		return err
+	}
+	if canary != nil {
+		services = append(services, *canary)
+	}
+
+	for _, service := range services {
+		fmt.Fprintf(dockboyCli.Out, "dockboy: removing service %s...\n", service.Spec.Name)
+		if err := dockerClient.ServiceRemove(ctx, service.ID); err != nil {
+			return fmt.Errorf("failed to remove service %s: %w", service.Spec.Name, err)
+		}
 	}
 
 	fmt.Fprintf(dockboyCli.Out, "dockboy: removing Caddy config for service %s...\n", conf.Name)
@@ -66,6 +81,15 @@ func runDestroy(ctx context.Context, dockboyCli *command.Cli, yes bool) error {
 		return fmt.Errorf("failed to remove Caddy config for service %s: %w", conf.Name, err)
 	}
 
+	settings, err := command.ProxySettings(conf)
+	if err != nil {
+		return err
+	}
+
+	if err := caddy.PruneCertificates(ctx, dockboyCli.Out, sshClient, dockerClient, dockerhelper.DockboyPublicNetwork, settings); err != nil {
+		return fmt.Errorf("failed to remove certificates of %s: %w", conf.Name, err)
+	}
+
 	fmt.Fprintln(dockboyCli.Out, conf.Name)
 
 	return nil
@@ -4,12 +4,14 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log/slog"
 	"strings"
 
 	"github.com/d3witt/dockboy/cli/command"
 	"github.com/d3witt/dockboy/dockerhelper"
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/docker/docker/client"
+	"github.com/docker/go-units"
 	"github.com/urfave/cli/v2"
 )
 
@@ -26,13 +28,28 @@ func NewInfoCmd(dockboyCli *command.Cli) *cli.Command {
 type appInfo struct {
 	Name        string
 	Status      string
+	Mode        string
+	Tasks       string
 	HealthError string
 
 	Image       string
+	Canary      string
+	Entrypoint  string
+	Command     string
+	User        string
+	WorkingDir  string
+	Hostname    string
 	Env         map[string]string
 	Labels      map[string]string
 	Secrets     []string
 	HealthCheck *healthCheckInfo
+	Resources   *resourcesInfo
+}
+
+type resourcesInfo struct {
+	CPU    string
+	Memory string
+	Pids   string
 }
 
 type healthCheckInfo struct {
@@ -79,7 +96,7 @@ func runInfo(ctx context.Context, dockboyCli *command.Cli) error {
 		return nil
 	}
 
-	service, err := dockerhelper.FindService(ctx, dockerClient, conf.Name)
+	service, err := dockerhelper.FindAppService(ctx, dockerClient, conf.Name)
 	if err != nil {
 		return fmt.Errorf("find service: %w", err)
 	}
@@ -93,6 +110,15 @@ func runInfo(ctx context.Context, dockboyCli *command.Cli) error {
 		return err
 	}
 
+	canary, err := dockerhelper.FindService(ctx, dockerClient, conf.Name+dockerhelper.CanaryServiceSuffix)
+	if err != nil {
+		return fmt.Errorf("find canary: %w", err)
+	}
+	if canary != nil {
+		info.Canary = fmt.Sprintf("%s (%s%% of traffic)",
+			canary.Spec.TaskTemplate.ContainerSpec.Image, canary.Spec.Labels[dockerhelper.CanaryWeightLabel])
+	}
+
 	printInfo(dockboyCli.Out, info)
 	return nil
 }
@@ -100,9 +126,21 @@ func runInfo(ctx context.Context, dockboyCli *command.Cli) error {
 func populateAppInfo(ctx context.Context, dockerClient *client.Client, service *swarm.Service, info *appInfo) error {
 	info.Status = "Deployed"
 	info.Image = service.Spec.TaskTemplate.ContainerSpec.Image
+	info.Entrypoint = strings.Join(service.Spec.TaskTemplate.ContainerSpec.Command, " ")
+	info.Command = strings.Join(service.Spec.TaskTemplate.ContainerSpec.Args, " ")
+	info.User = service.Spec.TaskTemplate.ContainerSpec.User
+	info.WorkingDir = service.Spec.TaskTemplate.ContainerSpec.Dir
+	info.Hostname = service.Spec.TaskTemplate.ContainerSpec.Hostname
 	info.Env = formatEnv(service.Spec.TaskTemplate.ContainerSpec.Env)
 	info.Labels = service.Spec.Labels
 	info.Secrets = formatSecrets(service.Spec.TaskTemplate.ContainerSpec.Secrets)
+	info.Mode = formatMode(service.Spec.Mode)
+
+	status, err := dockerhelper.ServiceStatus(ctx, dockerClient, service.ID)
+	if err != nil {
+		return fmt.Errorf("service status: %w", err)
+	}
+	info.Tasks = fmt.Sprintf("%d/%d running", status.RunningTasks, status.DesiredTasks)
 
 	if hc := service.Spec.TaskTemplate.ContainerSpec.Healthcheck; hc != nil {
 		info.HealthCheck = &healthCheckInfo{
@@ -129,6 +167,9 @@ func populateAppInfo(ctx context.Context, dockerClient *client.Client, service *
 
 		info.Status = "Deployed (" + containerStatus + ")"
 		info.HealthError = healthErr
+
+		usage := getResourceUsage(ctx, dockerClient, currentTasks)
+		info.Resources = formatResources(service.Spec.TaskTemplate.Resources, usage)
 	}
 
 	return nil
@@ -177,16 +218,110 @@ func getContainerStatus(ctx context.Context, dockerClient *client.Client, tasks
 	return "unknown", "", nil
 }
 
+func getResourceUsage(ctx context.Context, dockerClient *client.Client, tasks []swarm.Task) dockerhelper.ResourceUsage {
+	var total dockerhelper.ResourceUsage
+	for _, task := range tasks {
+		containerID := task.Status.ContainerStatus.ContainerID
+		if containerID == "" {
+			continue
+		}
+
+		usage, err := dockerhelper.ContainerResourceUsage(ctx, dockerClient, containerID)
+		if err != nil {
+			// The task may have stopped since it was listed, its usage is
+			// left out instead of failing the whole command.
+			slog.DebugContext(ctx, "Failed to get container stats", "container", containerID, "error", err)
+			continue
+		}
+
+		total.CPUPercent += usage.CPUPercent
+		total.MemoryBytes += usage.MemoryBytes
+		total.Pids += usage.Pids
+	}
+
+	return total
+}
+
+func formatResources(resources *swarm.ResourceRequirements, usage dockerhelper.ResourceUsage) *resourcesInfo {
+	var limits swarm.Limit
+	var reservations swarm.Resources
+	if resources != nil {
+		if resources.Limits != nil {
+			limits = *resources.Limits
+		}
+		if resources.Reservations != nil {
+			reservations = *resources.Reservations
+		}
+	}
+
+	info := &resourcesInfo{
+		CPU:    fmt.Sprintf("%.2f%%", usage.CPUPercent),
+		Memory: units.BytesSize(float64(usage.MemoryBytes)),
+		Pids:   fmt.Sprintf("%d", usage.Pids),
+	}
+
+	var cpu, memory []string
+	if limits.NanoCPUs > 0 {
+		cpu = append(cpu, fmt.Sprintf("limit %.2f CPUs", float64(limits.NanoCPUs)/1e9))
+	}
+	if reservations.NanoCPUs > 0 {
+		cpu = append(cpu, fmt.Sprintf("reserved %.2f CPUs", float64(reservations.NanoCPUs)/1e9))
+	}
+	if limits.MemoryBytes > 0 {
+		memory = append(memory, "limit "+units.BytesSize(float64(limits.MemoryBytes)))
+	}
+	if reservations.MemoryBytes > 0 {
+		memory = append(memory, "reserved "+units.BytesSize(float64(reservations.MemoryBytes)))
+	}
+
+	if len(cpu) > 0 {
+		info.CPU += " (" + strings.Join(cpu, ", ") + ")"
+	}
+	if len(memory) > 0 {
+		info.Memory += " (" + strings.Join(memory, ", ") + ")"
+	}
+	if limits.Pids > 0 {
+		info.Pids += fmt.Sprintf(" (limit %d)", limits.Pids)
+	}
+
+	return info
+}
+
 func printInfo(w io.Writer, info *appInfo) {
 	fmt.Fprintf(w, "Name: %s\n", info.Name)
 	fmt.Fprintf(w, "Status: %s\n", info.Status)
 
+	if info.Mode != "" {
+		fmt.Fprintf(w, "Mode: %s\n", info.Mode)
+		fmt.Fprintf(w, "Tasks: %s\n", info.Tasks)
+	}
+
 	if info.HealthError != "" {
 		fmt.Fprintf(w, "\nHealth Check Error:\n%s\n", info.HealthError)
 	}
 
 	fmt.Fprintf(w, "\nImage: %s\n", info.Image)
 
+	if info.Canary != "" {
+		fmt.Fprintf(w, "Canary: %s\n", info.Canary)
+	}
+
+	if info.Entrypoint != "" {
+		fmt.Fprintf(w, "Entrypoint: %s\n", info.Entrypoint)
+	}
+	if info.Command != "" {
+		fmt.Fprintf(w, "Command: %s\n", info.Command)
+	}
+	if info.User != "" {
+		fmt.Fprintf(w, "User: %s\n", info.User)
+	}
+	if info.WorkingDir != "" {
+		fmt.Fprintf(w, "Working Dir: %s\n", info.WorkingDir)
+	}
+	if info.Hostname != "" {
+		fmt.Fprintf(w, "Hostname: %s\n", info.Hostname)
+	}
+
 	if len(info.Env) > 0 {
 		fmt.Fprintf(w, "\nEnvironments:\n")
 		for k, v := range info.Env {
@@ -208,6 +343,13 @@ func printInfo(w io.Writer, info *appInfo) {
 		fmt.Fprintf(w, "  Timeout: %s\n", info.HealthCheck.Timeout)
 		fmt.Fprintf(w, "  Retries: %d\n", info.HealthCheck.Retries)
 	}
+
+	if info.Resources != nil {
+		fmt.Fprintf(w, "\nResources:\n")
+		fmt.Fprintf(w, "  CPU: %s\n", info.Resources.CPU)
+		fmt.Fprintf(w, "  Memory: %s\n", info.Resources.Memory)
+		fmt.Fprintf(w, "  Pids: %s\n", info.Resources.Pids)
+	}
 }
 
 func formatEnv(env []string) map[string]string {
@@ -220,6 +362,17 @@ func formatEnv(env []string) map[string]string {
 	return result
 }
 
+func formatMode(mode swarm.ServiceMode) string {
+	switch {
+	case mode.Global != nil:
+		return "global"
+	case mode.Replicated != nil && mode.Replicated.Replicas != nil:
+		return fmt.Sprintf("replicated (%d replicas)", *mode.Replicated.Replicas)
+	default:
+		return "replicated"
+	}
+}
+
 func formatSecrets(secrets []*swarm.SecretReference) []string {
 	var result []string
 	for _, secret := range secrets {
@@ -76,7 +76,15 @@ func runLogs(ctx context.Context, dockboyCli *command.Cli, tail int, since strin
 		}
 	}
 
-	reader, err := dockerClient.ServiceLogs(ctx, conf.Name, options)
+	service, err := dockerhelper.FindAppService(ctx, dockerClient, conf.Name)
+	if err != nil {
+		return err
+	}
+	if service == nil {
+		return fmt.Errorf("service %s not found", conf.Name)
+	}
+
+	reader, err := dockerClient.ServiceLogs(ctx, service.ID, options)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,88 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/d3witt/dockboy/caddy"
+	"github.com/d3witt/dockboy/cli/command"
+	"github.com/d3witt/dockboy/dockerhelper"
+	"github.com/urfave/cli/v2"
+)
+
+const defaultMaintenanceMessage = "Down for maintenance. Please try again later."
+
+func NewMaintenanceCmd(dockboyCli *command.Cli) *cli.Command {
+	return &cli.Command{
+		Name:  "maintenance",
+		Usage: "Show a maintenance page instead of the app",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "on",
+				Usage: "Respond with 503 and a maintenance message",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "message",
+						Usage: "The message to show",
+						Value: defaultMaintenanceMessage,
+					},
+					&cli.DurationFlag{
+						Name:  "retry-after",
+						Usage: "When clients should try again, sent as the Retry-After header",
+						Value: 5 * time.Minute,
+					},
+					&cli.StringSliceFlag{
+						Name:  "allow-ip",
+						Usage: "IP address or CIDR range that still reaches the app",
+					},
+				},
+				Action: func(ctx *cli.Context) error {
+					return runMaintenance(ctx.Context, dockboyCli, &caddy.Maintenance{
+						Message:    ctx.String("message"),
+						RetryAfter: ctx.Duration("retry-after"),
+						AllowIPs:   ctx.StringSlice("allow-ip"),
+					})
+				},
+			},
+			{
+				Name:  "off",
+				Usage: "Serve the app again",
+				Action: func(ctx *cli.Context) error {
+					return runMaintenance(ctx.Context, dockboyCli, nil)
+				},
+			},
+		},
+	}
+}
+
+func runMaintenance(ctx context.Context, dockboyCli *command.Cli, m *caddy.Maintenance) error {
+	conf, err := dockboyCli.AppConfig()
+	if err != nil {
+		return err
+	}
+
+	sshClient, err := dockboyCli.DialMachine()
+	if err != nil {
+		return err
+	}
+	defer sshClient.Close()
+
+	dockerClient, err := dockerhelper.DialSSH(sshClient)
+	if err != nil {
+		return err
+	}
+	defer dockerClient.Close()
+
+	if err := caddy.SetMaintenance(ctx, sshClient, dockerClient, conf.Name, m); err != nil {
+		return fmt.Errorf("failed to configure maintenance mode: %w", err)
+	}
+
+	if m != nil {
+		fmt.Fprintf(dockboyCli.Out, "dockboy: maintenance mode is on for %s\n", conf.Name)
+	} else {
+		fmt.Fprintf(dockboyCli.Out, "dockboy: maintenance mode is off for %s\n", conf.Name)
+	}
+
+	return nil
+}
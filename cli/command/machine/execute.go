@@ -7,7 +7,6 @@ import (
 	"github.com/d3witt/dockboy/cli/command"
 	"github.com/d3witt/dockboy/sshexec"
 	"github.com/urfave/cli/v2"
-	"golang.org/x/crypto/ssh"
 )
 
 func NewExecuteCmd(dockboyCli *command.Cli) *cli.Command {
@@ -45,7 +44,7 @@ func runExecute(dockboyCli *command.Cli, cmd string, tty bool) error {
 	return executeCmd(dockboyCli, sshClient, cmd)
 }
 
-func executeCmd(dockboyCli *command.Cli, client *ssh.Client, cmd string) error {
+func executeCmd(dockboyCli *command.Cli, client *sshexec.Client, cmd string) error {
 	sshCmd := sshexec.Command(client, cmd)
 	output, err := sshCmd.CombinedOutput()
 
@@ -57,7 +56,7 @@ func executeCmd(dockboyCli *command.Cli, client *ssh.Client, cmd string) error {
 	return nil
 }
 
-func executeTTY(dockboyCli *command.Cli, client *ssh.Client, cmd string) error {
+func executeTTY(dockboyCli *command.Cli, client *sshexec.Client, cmd string) error {
 	sshCmd := sshexec.Command(client, cmd)
 
 	w, h, err := dockboyCli.In.Size()
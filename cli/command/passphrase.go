@@ -0,0 +1,102 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/zalando/go-keyring"
+)
+
+// keyringService is the service name passphrases are stored under in the OS
+// keyring.
+const keyringService = "dockboy"
+
+// passphrases asks for the passphrases of encrypted identity files: the one
+// from the config first, then the one cached in the OS keyring, then the
+// user.
+type passphrases struct {
+	cli *Cli
+	// configured is the passphrase of configuredFile from the config.
+	configured     string
+	configuredFile string
+	keyring        bool
+
+	mu       sync.Mutex
+	accepted map[string][]byte
+	// fromKeyring records the files whose last passphrase came from the
+	// keyring, so a wrong one is removed.
+	fromKeyring map[string]bool
+}
+
+func newPassphrases(cli *Cli, configuredFile, configured string, useKeyring bool) *passphrases {
+	return &passphrases{
+		cli:            cli,
+		configured:     configured,
+		configuredFile: configuredFile,
+		keyring:        useKeyring,
+		accepted:       make(map[string][]byte),
+		fromKeyring:    make(map[string]bool),
+	}
+}
+
+func (p *passphrases) Passphrase(file string, retry bool) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !retry {
+		// Reconnecting decrypts the key again.
+		if passphrase, ok := p.accepted[file]; ok {
+			return passphrase, nil
+		}
+		if p.configured != "" && file == p.configuredFile {
+			return []byte(p.configured), nil
+		}
+		if p.keyring {
+			passphrase, err := keyring.Get(keyringService, file)
+			if err == nil {
+				p.fromKeyring[file] = true
+				return []byte(passphrase), nil
+			}
+			if !errors.Is(err, keyring.ErrNotFound) {
+				slog.Debug("Failed to read passphrase from keyring", "file", file, "error", err)
+			}
+		}
+	} else if p.fromKeyring[file] {
+		p.fromKeyring[file] = false
+		if err := keyring.Delete(keyringService, file); err != nil {
+			slog.Debug("Failed to remove passphrase from keyring", "file", file, "error", err)
+		}
+	}
+
+	if !p.cli.In.IsTerminal() {
+		return nil, fmt.Errorf("identity file %s is encrypted, add it to ssh-agent or run dockboy in a terminal", file)
+	}
+
+	if retry {
+		fmt.Fprintln(p.cli.Err, "Wrong passphrase, try again.")
+	}
+	fmt.Fprintf(p.cli.Err, "Enter passphrase for key '%s': ", file)
+	passphrase, err := p.cli.In.ReadPassword()
+	fmt.Fprintln(p.cli.Err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read passphrase: %w", err)
+	}
+
+	return passphrase, nil
+}
+
+func (p *passphrases) Accepted(file string, passphrase []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.accepted[file] = passphrase
+	if !p.keyring || p.fromKeyring[file] || (file == p.configuredFile && string(passphrase) == p.configured) {
+		return
+	}
+
+	if err := keyring.Set(keyringService, file, string(passphrase)); err != nil {
+		slog.Warn("Failed to store passphrase in keyring", "file", file, "error", err)
+	}
+}
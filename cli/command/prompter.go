@@ -0,0 +1,62 @@
+package command
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// sshPrompter asks the user for passwords and keyboard-interactive answers
+// while connecting to a machine. It needs a terminal.
+type sshPrompter struct {
+	cli *Cli
+}
+
+func (p *sshPrompter) Password(user, host string) (string, error) {
+	return p.readSecret(fmt.Sprintf("%s@%s's password: ", user, host))
+}
+
+func (p *sshPrompter) Challenge(name, instruction string, questions []string, echos []bool) ([]string, error) {
+	if name != "" {
+		fmt.Fprintln(p.cli.Err, name)
+	}
+	if instruction != "" {
+		fmt.Fprintln(p.cli.Err, instruction)
+	}
+
+	answers := make([]string, len(questions))
+	for i, question := range questions {
+		var err error
+		if echos[i] {
+			answers[i], err = p.readLine(question)
+		} else {
+			answers[i], err = p.readSecret(question)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return answers, nil
+}
+
+func (p *sshPrompter) readSecret(prompt string) (string, error) {
+	fmt.Fprint(p.cli.Err, prompt)
+	secret, err := p.cli.In.ReadPassword()
+	fmt.Fprintln(p.cli.Err)
+	if err != nil {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+
+	return string(secret), nil
+}
+
+func (p *sshPrompter) readLine(prompt string) (string, error) {
+	fmt.Fprint(p.cli.Err, prompt)
+	line, _, err := bufio.NewReader(p.cli.In).ReadLine()
+	if err != nil {
+		return "", fmt.Errorf("failed to read input: %w", err)
+	}
+
+	return strings.TrimSpace(string(line)), nil
+}
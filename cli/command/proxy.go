@@ -0,0 +1,102 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/d3witt/dockboy/caddy"
+	"github.com/d3witt/dockboy/config"
+	"github.com/d3witt/dockboy/dockerhelper"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/client"
+)
+
+// ProxySettings returns the Caddy settings of the machine the app deploys to.
+func ProxySettings(conf config.Config) (caddy.Settings, error) {
+	settings := caddy.Settings{
+		Image:       conf.Proxy.Image,
+		Version:     conf.Proxy.Version,
+		PublishMode: swarm.PortConfigPublishMode(conf.Proxy.PublishMode),
+		Modules:     conf.Proxy.Build.Modules,
+		ACME: caddy.ACME{
+			Email:       conf.Proxy.ACME.Email,
+			CA:          conf.Proxy.ACME.CA,
+			OnDemandAsk: conf.Proxy.ACME.OnDemandAsk,
+		},
+	}
+
+	if conf.Proxy.ACME.CARoot != "" {
+		root, err := os.ReadFile(conf.Proxy.ACME.CARoot)
+		if err != nil {
+			return settings, fmt.Errorf("failed to read ACME CA root: %w", err)
+		}
+		settings.ACME.CARoot = root
+	}
+
+	if dns := conf.Proxy.ACME.DNS; dns.Provider != "" {
+		credentials, err := ParseSecrets(dns.Credentials)
+		if err != nil {
+			return settings, err
+		}
+		settings.ACME.DNS = &caddy.DNSProvider{
+			Name:        dns.Provider,
+			Credentials: credentials,
+		}
+	} else if len(dns.Credentials) > 0 {
+		return settings, fmt.Errorf("proxy.acme.dns.provider is required")
+	}
+
+	return settings, nil
+}
+
+// PrepareProxyImage builds the custom Caddy image if the proxy has modules
+// and the remote host does not have the image yet. The image is built on the
+// local machine and sent to the host like app images, or built on the host.
+func PrepareProxyImage(ctx context.Context, out io.Writer, remote *client.Client, conf config.Config) error {
+	settings, err := ProxySettings(conf)
+	if err != nil {
+		return err
+	}
+	if !settings.NeedsBuild() {
+		return nil
+	}
+
+	imageName := settings.ImageRef()
+	exists, err := dockerhelper.ImageExists(ctx, remote, imageName)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	dockerfile, err := settings.Dockerfile()
+	if err != nil {
+		return err
+	}
+
+	platform, err := dockerhelper.Platform(ctx, remote)
+	if err != nil {
+		return err
+	}
+
+	if conf.Proxy.Build.OnHost {
+		fmt.Fprintf(out, "dockboy: building Caddy image %s on remote host...\n", imageName)
+		return dockerhelper.BuildImage(ctx, out, remote, imageName, platform, dockerfile)
+	}
+
+	local, err := client.NewClientWithOpts(client.FromEnv)
+	if err != nil {
+		return fmt.Errorf("failed to create local Docker client: %w", err)
+	}
+	defer local.Close()
+
+	fmt.Fprintf(out, "dockboy: building Caddy image %s for %s...\n", imageName, platform)
+	if err := dockerhelper.BuildImage(ctx, out, local, imageName, platform, dockerfile); err != nil {
+		return err
+	}
+
+	return dockerhelper.SendImage(ctx, out, remote, imageName)
+}
@@ -0,0 +1,55 @@
+package proxy
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/d3witt/dockboy/caddy"
+	"github.com/d3witt/dockboy/cli/command"
+	"github.com/d3witt/dockboy/sshexec"
+	"github.com/docker/docker/client"
+	"github.com/urfave/cli/v2"
+)
+
+func NewCertsCmd(dockboyCli *command.Cli) *cli.Command {
+	return &cli.Command{
+		Name:  "certs",
+		Usage: "Manage the TLS certificates served by the proxy",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "list",
+				Usage: "List certificates with their issuer and expiry",
+				Action: func(ctx *cli.Context) error {
+					return runCertsList(ctx.Context, dockboyCli)
+				},
+			},
+		},
+	}
+}
+
+func runCertsList(ctx context.Context, dockboyCli *command.Cli) error {
+	return withMachine(dockboyCli, func(sshClient *sshexec.Client, dockerClient *client.Client) error {
+		certs, err := caddy.ListCertificates(ctx, dockerClient)
+		if err != nil {
+			return err
+		}
+
+		data := [][]string{{"DOMAIN", "ISSUER", "EXPIRES", "SOURCE"}}
+		for _, cert := range certs {
+			expires := cert.Expires.Local().Format(time.DateOnly)
+			if time.Now().After(cert.Expires) {
+				expires += " (expired)"
+			}
+
+			source := "managed"
+			if cert.App != "" {
+				source = "uploaded by " + cert.App
+			}
+
+			data = append(data, []string{strings.Join(cert.Domains, ", "), cert.Issuer, expires, source})
+		}
+
+		return command.PrintTable(dockboyCli.Out, data)
+	})
+}
@@ -0,0 +1,191 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/d3witt/dockboy/caddy"
+	"github.com/d3witt/dockboy/cli/command"
+	"github.com/d3witt/dockboy/dockerhelper"
+	"github.com/d3witt/dockboy/sshexec"
+	"github.com/docker/docker/client"
+	"github.com/urfave/cli/v2"
+)
+
+func NewProxyCmd(dockboyCli *command.Cli) *cli.Command {
+	return &cli.Command{
+		Name:  "proxy",
+		Usage: "Manage the Caddy proxy shared by all apps on the machine",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "upgrade",
+				Usage: "Roll the proxy out with the configured image and settings",
+				Action: func(ctx *cli.Context) error {
+					return runUpgrade(ctx.Context, dockboyCli)
+				},
+			},
+			{
+				Name:  "restart",
+				Usage: "Restart the proxy",
+				Action: func(ctx *cli.Context) error {
+					return runRestart(ctx.Context, dockboyCli)
+				},
+			},
+			{
+				Name:  "info",
+				Usage: "Display information about the proxy",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "live",
+						Usage: "Print the JSON config Caddy is running",
+					},
+				},
+				Action: func(ctx *cli.Context) error {
+					if ctx.Bool("live") {
+						return runLiveConfig(ctx.Context, dockboyCli)
+					}
+					return runInfo(ctx.Context, dockboyCli)
+				},
+			},
+		},
+	}
+}
+
+func runUpgrade(ctx context.Context, dockboyCli *command.Cli) error {
+	conf, err := dockboyCli.AppConfig()
+	if err != nil {
+		return err
+	}
+
+	return withMachine(dockboyCli, func(sshClient *sshexec.Client, dockerClient *client.Client) error {
+		settings, err := command.ProxySettings(conf)
+		if err != nil {
+			return err
+		}
+
+		info, err := caddy.Inspect(ctx, dockerClient, dockerhelper.DockboyPublicNetwork, settings)
+		if err != nil {
+			return err
+		}
+		if info == nil {
+			return fmt.Errorf("proxy not found, deploy an app first")
+		}
+
+		if !info.UpToDate {
+			if err := command.PrepareProxyImage(ctx, dockboyCli.Out, dockerClient, conf); err != nil {
+				return fmt.Errorf("failed to prepare Caddy image: %w", err)
+			}
+		}
+
+		// Global options, such as the ACME settings, are applied even if the
+		// service itself is up to date.
+		if err := caddy.UpgradeCaddyService(ctx, dockboyCli.Out, sshClient, dockerClient, dockerhelper.DockboyPublicNetwork, settings); err != nil {
+			return err
+		}
+
+		if info.UpToDate {
+			fmt.Fprintf(dockboyCli.Out, "dockboy: proxy is up to date (%s)\n", info.Image)
+		} else {
+			fmt.Fprintf(dockboyCli.Out, "dockboy: proxy upgraded to %s\n", settings.ImageRef())
+		}
+		return nil
+	})
+}
+
+func runRestart(ctx context.Context, dockboyCli *command.Cli) error {
+	return withMachine(dockboyCli, func(sshClient *sshexec.Client, dockerClient *client.Client) error {
+		if err := caddy.RestartCaddyService(ctx, dockboyCli.Out, sshClient, dockerClient); err != nil {
+			return err
+		}
+
+		fmt.Fprintln(dockboyCli.Out, "dockboy: proxy restarted")
+		return nil
+	})
+}
+
+func runInfo(ctx context.Context, dockboyCli *command.Cli) error {
+	conf, err := dockboyCli.AppConfig()
+	if err != nil {
+		return err
+	}
+
+	return withMachine(dockboyCli, func(sshClient *sshexec.Client, dockerClient *client.Client) error {
+		settings, err := command.ProxySettings(conf)
+		if err != nil {
+			return err
+		}
+
+		info, err := caddy.Inspect(ctx, dockerClient, dockerhelper.DockboyPublicNetwork, settings)
+		if err != nil {
+			return err
+		}
+		if info == nil {
+			fmt.Fprintln(dockboyCli.Out, "Status: Not Deployed")
+			return nil
+		}
+
+		status := "up to date"
+		if !info.UpToDate {
+			status = fmt.Sprintf("outdated, run 'dockboy proxy upgrade' to roll out %s", info.DesiredImage)
+		}
+
+		data := [][]string{
+			{"Image:", info.Image},
+			{"Config:", status},
+			{"Tasks:", fmt.Sprintf("%d/%d running", info.RunningTasks, info.DesiredTasks)},
+		}
+
+		apps := make([]string, 0, len(info.Sites))
+		for app := range info.Sites {
+			apps = append(apps, app)
+		}
+		sort.Strings(apps)
+
+		if len(apps) > 0 {
+			data = append(data, []string{"Sites:", ""})
+			for _, app := range apps {
+				data = append(data, []string{"  " + app + ":", strings.Join(info.Sites[app], ", ")})
+			}
+		}
+
+		return command.PrintTable(dockboyCli.Out, data)
+	})
+}
+
+func runLiveConfig(ctx context.Context, dockboyCli *command.Cli) error {
+	return withMachine(dockboyCli, func(sshClient *sshexec.Client, dockerClient *client.Client) error {
+		config, err := caddy.LiveConfig(ctx, sshClient, dockerClient)
+		if err != nil {
+			return err
+		}
+
+		var out bytes.Buffer
+		if err := json.Indent(&out, bytes.TrimSpace(config), "", "  "); err != nil {
+			return fmt.Errorf("failed to format Caddy config: %w", err)
+		}
+		out.WriteByte('\n')
+
+		_, err = out.WriteTo(dockboyCli.Out)
+		return err
+	})
+}
+
+func withMachine(dockboyCli *command.Cli, fn func(*sshexec.Client, *client.Client) error) error {
+	sshClient, err := dockboyCli.DialMachine()
+	if err != nil {
+		return err
+	}
+	defer sshClient.Close()
+
+	dockerClient, err := dockerhelper.DialSSH(sshClient)
+	if err != nil {
+		return err
+	}
+	defer dockerClient.Close()
+
+	return fn(sshClient, dockerClient)
+}
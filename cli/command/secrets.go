@@ -0,0 +1,30 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ParseSecrets returns the secret values by name. Values of keys ending with
+// _file are read from the file at that path, and the suffix is dropped.
+func ParseSecrets(secrets map[string]string) (map[string][]byte, error) {
+	res := make(map[string][]byte)
+	for key, value := range secrets {
+		if value == "" {
+			continue
+		}
+
+		if strings.HasSuffix(key, "_file") {
+			content, err := os.ReadFile(value)
+			if err != nil {
+				return res, fmt.Errorf("failed to read secret file %s: %w", value, err)
+			}
+			res[strings.TrimSuffix(key, "_file")] = content
+		} else {
+			res[key] = []byte(value)
+		}
+	}
+
+	return res, nil
+}
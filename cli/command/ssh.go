@@ -2,13 +2,15 @@ package command
 
 import (
 	"fmt"
-	"os"
+	"net"
+	"strconv"
 
+	"github.com/d3witt/dockboy/config"
 	"github.com/d3witt/dockboy/sshexec"
 	"golang.org/x/crypto/ssh"
 )
 
-func (c *Cli) DialMachine() (*ssh.Client, error) {
+func (c *Cli) DialMachine() (*sshexec.Client, error) {
 	conf, err := c.AppConfig()
 	if err != nil {
 		return nil, err
@@ -19,15 +21,77 @@ func (c *Cli) DialMachine() (*ssh.Client, error) {
 		return nil, err
 	}
 
-	var private, passphrase string
-	if m.IdentityFile != "" {
-		key, err := os.ReadFile(m.IdentityFile)
+	// Passphrases are shared by the machine and the jump hosts, so a key
+	// used for several of them is asked for once.
+	passphrase := newPassphrases(c, m.IdentityFile, m.Passphrase, m.Keyring)
+
+	target, err := c.sshHost(m, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	jumps := make([]sshexec.Host, 0, len(m.Jumps))
+	for _, jump := range m.Jumps {
+		host, err := c.sshHost(jump, passphrase)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read identity file: %w", err)
+			return nil, err
 		}
-		private = string(key)
-		passphrase = m.Passphrase
+		jumps = append(jumps, host)
+	}
+
+	return sshexec.Connect(func() (*ssh.Client, error) {
+		return sshexec.SSHClient(target, jumps...)
+	})
+}
+
+func (c *Cli) sshHost(m config.Machine, passphrase sshexec.PassphraseProvider) (sshexec.Host, error) {
+	hostKeyCallback, hostKeyAlgorithms, err := c.hostKeyCallback(m)
+	if err != nil {
+		return sshexec.Host{}, err
+	}
+
+	// Without a terminal, password and keyboard-interactive authentication
+	// are skipped.
+	var prompter sshexec.Prompter
+	if c.In.IsTerminal() {
+		prompter = &sshPrompter{cli: c}
+	}
+
+	return sshexec.Host{
+		Host:              m.Host,
+		Port:              m.Port,
+		User:              m.User,
+		IdentityFile:      m.IdentityFile,
+		Passphrase:        passphrase,
+		AuthMethods:       m.AuthMethods,
+		Prompter:          prompter,
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: hostKeyAlgorithms,
+	}, nil
+}
+
+// hostKeyCallback verifies the machine against its pinned host key, or
+// against known_hosts, asking the user to trust unknown hosts.
+func (c *Cli) hostKeyCallback(m config.Machine) (ssh.HostKeyCallback, []string, error) {
+	if m.HostKey != "" {
+		callback, err := sshexec.PinnedHostKey(m.HostKey)
+		return callback, sshexec.PinnedHostKeyAlgorithms(m.HostKey), err
+	}
+
+	file, err := sshexec.DefaultKnownHostsFile()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	callback, err := sshexec.KnownHosts(file, func(host string, key ssh.PublicKey) (bool, error) {
+		fmt.Fprintf(c.Out, "dockboy: the authenticity of host %s can't be established.\n", host)
+		fmt.Fprintf(c.Out, "dockboy: %s key fingerprint is %s.\n", key.Type(), ssh.FingerprintSHA256(key))
+		return PromptForConfirmation(c.In, c.Out, "Are you sure you want to continue connecting?")
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return sshexec.SSHClient(m.IP.String(), m.Port, m.User, private, passphrase)
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	return callback, sshexec.KnownHostKeyAlgorithms(file, addr), nil
 }
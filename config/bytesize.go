@@ -0,0 +1,14 @@
+package config
+
+import "github.com/docker/go-units"
+
+type ByteSize int64
+
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	x, err := units.RAMInBytes(string(text))
+	if err != nil {
+		return err
+	}
+	*b = ByteSize(x)
+	return nil
+}
@@ -7,24 +7,154 @@ import (
 type Config struct {
 	Name        string            `toml:"name"`
 	Image       string            `toml:"image"`
+	Command     []string          `toml:"command,omitempty"`
+	Entrypoint  []string          `toml:"entrypoint,omitempty"`
+	User        string            `toml:"user,omitempty"`
+	WorkingDir  string            `toml:"working_dir,omitempty"`
+	Hostname    string            `toml:"hostname,omitempty"`
 	Machine     Machine           `toml:"machine"`
-	Public      PublicConfig      `toml:"public,omitempty"`
+	Proxy       ProxyConfig       `toml:"proxy,omitempty"`
+	Public      []PublicConfig    `toml:"public,omitempty"`
+	Ports       []PortConfig      `toml:"ports,omitempty"`
+	Mode        string            `toml:"mode,omitempty"`
 	Replicas    uint64            `toml:"replicas,omitempty"`
 	Volumes     map[string]string `toml:"volumes,omitempty"`
 	Env         map[string]string `toml:"env,omitempty"`
 	Secrets     map[string]string `toml:"secrets,omitempty"`
 	Label       map[string]string `toml:"label,omitempty"`
 	Healthcheck HealthConfig      `toml:"healthcheck,omitempty"`
+	Resources   ResourcesConfig   `toml:"resources,omitempty"`
 	Deploy      DeployConfig      `toml:"deploy,omitempty"`
+
+	StopSignal      string   `toml:"stop_signal,omitempty"`
+	StopGracePeriod Duration `toml:"stop_grace_period,omitempty"`
+}
+
+type ResourcesConfig struct {
+	CPUs        float64           `toml:"cpus,omitempty"`
+	Memory      ByteSize          `toml:"memory,omitempty"`
+	PidsLimit   int64             `toml:"pids_limit,omitempty"`
+	Reservation ReservationConfig `toml:"reservation,omitempty"`
+}
+
+type ReservationConfig struct {
+	CPUs   float64  `toml:"cpus,omitempty"`
+	Memory ByteSize `toml:"memory,omitempty"`
 }
 
 type DeployConfig struct {
-	Order string `toml:"order,omitempty"`
+	Strategy        string              `toml:"strategy,omitempty"`
+	Order           string              `toml:"order,omitempty"`
+	Parallelism     uint64              `toml:"parallelism,omitempty"`
+	Delay           Duration            `toml:"delay,omitempty"`
+	Monitor         Duration            `toml:"monitor,omitempty"`
+	MaxFailureRatio float32             `toml:"max_failure_ratio,omitempty"`
+	FailureAction   string              `toml:"failure_action,omitempty"`
+	Rollback        RollbackConfig      `toml:"rollback,omitempty"`
+	RestartPolicy   RestartPolicyConfig `toml:"restart_policy,omitempty"`
+}
+
+type RollbackConfig struct {
+	Parallelism     uint64   `toml:"parallelism,omitempty"`
+	Delay           Duration `toml:"delay,omitempty"`
+	Monitor         Duration `toml:"monitor,omitempty"`
+	MaxFailureRatio float32  `toml:"max_failure_ratio,omitempty"`
+	FailureAction   string   `toml:"failure_action,omitempty"`
+}
+
+type RestartPolicyConfig struct {
+	Condition   string   `toml:"condition,omitempty"`
+	Delay       Duration `toml:"delay,omitempty"`
+	MaxAttempts uint64   `toml:"max_attempts,omitempty"`
+	Window      Duration `toml:"window,omitempty"`
+}
+
+// ProxyConfig configures the Caddy service shared by all apps on the machine.
+type ProxyConfig struct {
+	Image       string           `toml:"image,omitempty"`
+	Version     string           `toml:"version,omitempty"`
+	PublishMode string           `toml:"publish_mode,omitempty"`
+	Build       ProxyBuildConfig `toml:"build,omitempty"`
+	ACME        ACMEConfig       `toml:"acme,omitempty"`
+}
+
+// ACMEConfig configures how Caddy obtains certificates.
+type ACMEConfig struct {
+	Email       string        `toml:"email,omitempty"`
+	CA          string        `toml:"ca,omitempty"`
+	CARoot      string        `toml:"ca_root,omitempty"`
+	OnDemandAsk string        `toml:"on_demand_ask,omitempty"`
+	DNS         ACMEDNSConfig `toml:"dns,omitempty"`
+}
+
+type ACMEDNSConfig struct {
+	Provider    string            `toml:"provider,omitempty"`
+	Credentials map[string]string `toml:"credentials,omitempty"`
+}
+
+// ProxyBuildConfig lists Caddy modules to build into a custom image.
+type ProxyBuildConfig struct {
+	Modules []string `toml:"modules,omitempty"`
+	OnHost  bool     `toml:"on_host,omitempty"`
 }
 
 type PublicConfig struct {
-	Address    string `toml:"address,omitempty"`
-	TargetPort int    `toml:"target_port,omitempty"`
+	Address     string   `toml:"address,omitempty"`
+	Aliases     []string `toml:"aliases,omitempty"`
+	Path        string   `toml:"path,omitempty"`
+	StripPrefix bool     `toml:"strip_prefix,omitempty"`
+	RedirectTo  string   `toml:"redirect_to,omitempty"`
+	TargetPort  int      `toml:"target_port,omitempty"`
+
+	TLS           PublicTLSConfig     `toml:"tls,omitempty"`
+	Options       PublicOptionsConfig `toml:"options,omitempty"`
+	LoadBalancing LoadBalancingConfig `toml:"load_balancing,omitempty"`
+}
+
+type PublicTLSConfig struct {
+	Cert     string `toml:"cert,omitempty"`
+	Key      string `toml:"key,omitempty"`
+	Internal bool   `toml:"internal,omitempty"`
+	OnDemand bool   `toml:"on_demand,omitempty"`
+}
+
+type PublicOptionsConfig struct {
+	Headers         map[string]string `toml:"headers,omitempty"`
+	SecurityHeaders bool              `toml:"security_headers,omitempty"`
+	Encode          []string          `toml:"encode,omitempty"`
+	BasicAuth       map[string]string `toml:"basic_auth,omitempty"`
+	AllowIPs        []string          `toml:"allow_ips,omitempty"`
+	CORS            CORSConfig        `toml:"cors,omitempty"`
+}
+
+type CORSConfig struct {
+	Origins     []string `toml:"origins,omitempty"`
+	Methods     []string `toml:"methods,omitempty"`
+	Headers     []string `toml:"headers,omitempty"`
+	Credentials bool     `toml:"credentials,omitempty"`
+	MaxAge      Duration `toml:"max_age,omitempty"`
+}
+
+type LoadBalancingConfig struct {
+	Policy      string              `toml:"policy,omitempty"`
+	Cookie      string              `toml:"cookie,omitempty"`
+	Retries     int                 `toml:"retries,omitempty"`
+	TryDuration Duration            `toml:"try_duration,omitempty"`
+	Passive     PassiveHealthConfig `toml:"passive,omitempty"`
+}
+
+type PassiveHealthConfig struct {
+	FailDuration     Duration `toml:"fail_duration,omitempty"`
+	MaxFails         int      `toml:"max_fails,omitempty"`
+	UnhealthyStatus  []string `toml:"unhealthy_status,omitempty"`
+	UnhealthyLatency Duration `toml:"unhealthy_latency,omitempty"`
+}
+
+type PortConfig struct {
+	Published uint32 `toml:"published"`
+	Target    uint32 `toml:"target,omitempty"`
+	Protocol  string `toml:"protocol,omitempty"`
+	Mode      string `toml:"mode,omitempty"`
 }
 
 type HealthConfig struct {
@@ -41,7 +171,6 @@ func defaultConfig(name string) Config {
 		Name:    name,
 		Image:   "hashicorp/http-echo:latest",
 		Machine: Machine{},
-		Public:  PublicConfig{},
 	}
 }
 
@@ -57,6 +57,28 @@ func parseConfigFile(filename string) (cfg Config, err error) {
 		return cfg, err
 	}
 
+	data, err = normalizeConfig(data)
+	if err != nil {
+		return cfg, err
+	}
+
 	err = toml.Unmarshal(data, &cfg)
 	return
 }
+
+// normalizeConfig converts a single [public] table, as written by older
+// versions, into the [[public]] array of tables.
+func normalizeConfig(data []byte) ([]byte, error) {
+	var raw map[string]any
+	if err := toml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	public, ok := raw["public"].(map[string]any)
+	if !ok {
+		return data, nil
+	}
+	raw["public"] = []any{public}
+
+	return toml.Marshal(raw)
+}
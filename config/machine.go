@@ -2,26 +2,216 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kevinburke/ssh_config"
 )
 
 type Machine struct {
-	IP           net.IP `toml:"ip"`
+	// Host is a hostname, an IP address or a Host alias from ~/.ssh/config.
+	Host         string `toml:"host,omitempty"`
+	IP           net.IP `toml:"ip,omitempty"`
 	Port         int    `toml:"port,omitempty"`
 	User         string `toml:"user,omitempty"`
 	IdentityFile string `toml:"identity_file,omitempty"`
-	Passphrase   string `toml:"passphrase,omitempty"`
+	// Passphrase is stored in plain text, prefer ssh-agent or Keyring.
+	Passphrase string `toml:"passphrase,omitempty"`
+	// Keyring caches the passphrase of the identity file in the OS keyring
+	// after it was entered.
+	Keyring bool `toml:"keyring,omitempty"`
+	// HostKey pins the server's host key, in authorized_keys format or as a
+	// SHA256 fingerprint. If empty, ~/.ssh/known_hosts is used.
+	HostKey string `toml:"host_key,omitempty"`
+	// ProxyJump lists the jump hosts to connect through, in the format of
+	// the ssh_config option.
+	ProxyJump string `toml:"proxy_jump,omitempty"`
+	// AuthMethods are the authentication methods tried in order, like
+	// PreferredAuthentications in ssh_config.
+	AuthMethods []string `toml:"auth_methods,omitempty"`
+
+	// Jumps are the resolved jump hosts, in the order they are dialed.
+	Jumps []Machine `toml:"-"`
 }
 
 var ErrMachineNotFound = errors.New("machine not found")
 
+// maxJumps guards against ProxyJump loops in ~/.ssh/config.
+const maxJumps = 10
+
+// GetMachine returns the machine with the settings from ~/.ssh/config
+// applied. Settings in dockboy.toml take precedence.
 func (c *Config) GetMachine() (Machine, error) {
 	machine := c.Machine
-	setMachineDefaults(&machine)
+	if machine.Host == "" && machine.IP != nil {
+		machine.Host = machine.IP.String()
+	}
+	if machine.Host == "" {
+		return Machine{}, fmt.Errorf("machine host is not set")
+	}
+
+	if err := resolveMachine(&machine, 0); err != nil {
+		return Machine{}, err
+	}
 
 	return machine, nil
 }
 
+func resolveMachine(machine *Machine, depth int) error {
+	alias := machine.Host
+
+	hostname, err := sshConfig(alias, "HostName")
+	if err != nil {
+		return err
+	}
+	if hostname != "" {
+		machine.Host = strings.ReplaceAll(hostname, "%h", alias)
+	}
+
+	if machine.Port == 0 {
+		port, err := sshConfig(alias, "Port")
+		if err != nil {
+			return err
+		}
+		if port != "" {
+			machine.Port, err = strconv.Atoi(port)
+			if err != nil {
+				return fmt.Errorf("invalid port for %s in ssh config: %w", alias, err)
+			}
+		}
+	}
+
+	if machine.User == "" {
+		machine.User, err = sshConfig(alias, "User")
+		if err != nil {
+			return err
+		}
+	}
+
+	setMachineDefaults(machine)
+
+	if machine.IdentityFile == "" {
+		identityFile, err := sshConfig(alias, "IdentityFile")
+		if err != nil {
+			return err
+		}
+		if identityFile != "" {
+			machine.IdentityFile = expandSSHPath(identityFile, machine)
+		}
+	}
+
+	if len(machine.AuthMethods) == 0 {
+		preferred, err := sshConfig(alias, "PreferredAuthentications")
+		if err != nil {
+			return err
+		}
+		for _, method := range strings.Split(preferred, ",") {
+			// Methods dockboy doesn't support, such as gssapi-with-mic,
+			// are skipped.
+			switch method = strings.TrimSpace(method); method {
+			case "publickey", "keyboard-interactive", "password":
+				machine.AuthMethods = append(machine.AuthMethods, method)
+			}
+		}
+	}
+
+	proxyJump := machine.ProxyJump
+	if proxyJump == "" {
+		proxyJump, err = sshConfig(alias, "ProxyJump")
+		if err != nil {
+			return err
+		}
+	}
+	if proxyJump == "" || proxyJump == "none" {
+		return nil
+	}
+
+	if depth >= maxJumps {
+		return fmt.Errorf("too many jump hosts for %s, check ProxyJump for loops", alias)
+	}
+
+	for _, spec := range strings.Split(proxyJump, ",") {
+		jump, err := parseJump(strings.TrimSpace(spec))
+		if err != nil {
+			return err
+		}
+		if err := resolveMachine(&jump, depth+1); err != nil {
+			return err
+		}
+
+		// A jump host may itself be reached through jump hosts.
+		machine.Jumps = append(machine.Jumps, jump.Jumps...)
+		jump.Jumps = nil
+		machine.Jumps = append(machine.Jumps, jump)
+	}
+
+	return nil
+}
+
+// parseJump parses a ProxyJump entry: [user@]host[:port].
+func parseJump(spec string) (Machine, error) {
+	var jump Machine
+	if spec == "" {
+		return jump, fmt.Errorf("invalid jump host: empty entry")
+	}
+	spec = strings.TrimPrefix(spec, "ssh://")
+
+	if i := strings.LastIndex(spec, "@"); i >= 0 {
+		jump.User, spec = spec[:i], spec[i+1:]
+	}
+
+	if host, port, err := net.SplitHostPort(spec); err == nil {
+		jump.Port, err = strconv.Atoi(port)
+		if err != nil {
+			return jump, fmt.Errorf("invalid port of jump host %s: %w", spec, err)
+		}
+		spec = host
+	}
+	jump.Host = spec
+
+	return jump, nil
+}
+
+// sshConfig returns the value of an option set for the host in the user's or
+// the system's ssh config, ignoring the built-in defaults of the parser.
+func sshConfig(alias, key string) (string, error) {
+	value, err := ssh_config.GetStrict(alias, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to read ssh config: %w", err)
+	}
+	if value == ssh_config.Default(key) {
+		return "", nil
+	}
+	return value, nil
+}
+
+// expandSSHPath expands ~ and the tokens ssh_config allows in IdentityFile.
+func expandSSHPath(p string, machine *Machine) string {
+	home, _ := os.UserHomeDir()
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		p = filepath.Join(home, p[1:])
+	}
+
+	local := ""
+	if u, err := user.Current(); err == nil {
+		local = u.Username
+	}
+
+	return strings.NewReplacer(
+		"%d", home,
+		"%h", machine.Host,
+		"%p", strconv.Itoa(machine.Port),
+		"%r", machine.User,
+		"%u", local,
+		"%%", "%",
+	).Replace(p)
+}
+
 func setMachineDefaults(machine *Machine) {
 	if machine.Port == 0 {
 		machine.Port = 22
@@ -2,6 +2,7 @@ package dockerhelper
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -45,3 +46,56 @@ func ExecContainer(ctx context.Context, remote *client.Client, containerID strin
 		}
 	}
 }
+
+type ResourceUsage struct {
+	CPUPercent  float64
+	MemoryBytes uint64
+	Pids        uint64
+}
+
+func ContainerResourceUsage(ctx context.Context, remote *client.Client, containerID string) (ResourceUsage, error) {
+	resp, err := remote.ContainerStats(ctx, containerID, false)
+	if err != nil {
+		return ResourceUsage{}, fmt.Errorf("failed to get stats: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var stats container.StatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return ResourceUsage{}, fmt.Errorf("failed to decode stats: %w", err)
+	}
+
+	return ResourceUsage{
+		CPUPercent:  cpuPercent(stats),
+		MemoryBytes: memoryUsage(stats.MemoryStats),
+		Pids:        stats.PidsStats.Current,
+	}, nil
+}
+
+// cpuPercent follows the calculation used by `docker stats`.
+func cpuPercent(stats container.StatsResponse) float64 {
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+
+	onlineCPUs := float64(stats.CPUStats.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+	}
+
+	return cpuDelta / systemDelta * onlineCPUs * 100
+}
+
+// memoryUsage excludes the page cache, like `docker stats` does.
+func memoryUsage(mem container.MemoryStats) uint64 {
+	cache := mem.Stats["inactive_file"] // cgroup v2
+	if v, ok := mem.Stats["total_inactive_file"]; ok {
+		cache = v // cgroup v1
+	}
+	if cache > mem.Usage {
+		return mem.Usage
+	}
+	return mem.Usage - cache
+}
@@ -18,42 +18,59 @@ import (
 	"github.com/docker/docker/client"
 )
 
-func DeployService(
-	ctx context.Context,
-	out io.Writer,
-	docker *client.Client,
-	name, image string,
-	replicas uint64,
-	networks []string,
-	env, labels map[string]string,
-	secrets map[string][]byte,
-	healthcheck *container.HealthConfig,
-	mounts []mount.Mount,
-	order string,
-) error {
-	if order != swarm.UpdateOrderStartFirst && order != swarm.UpdateOrderStopFirst {
-		return fmt.Errorf("invalid order: %s", order)
-	}
+type ServiceConfig struct {
+	Name            string
+	Image           string
+	Entrypoint      []string
+	Command         []string
+	User            string
+	WorkingDir      string
+	Hostname        string
+	Mode            swarm.ServiceMode
+	Networks        []string
+	Env             map[string]string
+	Labels          map[string]string
+	Secrets         map[string][]byte
+	Healthcheck     *container.HealthConfig
+	Mounts          []mount.Mount
+	Ports           []swarm.PortConfig
+	Resources       *swarm.ResourceRequirements
+	RestartPolicy   *swarm.RestartPolicy
+	StopSignal      string
+	StopGracePeriod *time.Duration
+	UpdateConfig    *swarm.UpdateConfig
+	RollbackConfig  *swarm.UpdateConfig
+}
 
-	secretRefs, err := createSecrets(ctx, docker, secrets)
+func DeployService(ctx context.Context, out io.Writer, docker *client.Client, svc ServiceConfig) error {
+	secretRefs, err := createSecrets(ctx, docker, svc.Secrets)
 	if err != nil {
 		return err
 	}
 
 	spec := swarm.ServiceSpec{
 		Annotations: swarm.Annotations{
-			Name:   name,
-			Labels: labels,
+			Name:   svc.Name,
+			Labels: svc.Labels,
 		},
 		TaskTemplate: swarm.TaskSpec{
 			ContainerSpec: &swarm.ContainerSpec{
-				Image:       image,
-				Env:         mapToSlice(env),
-				Secrets:     secretRefs,
-				Healthcheck: healthcheck,
-				Mounts:      mounts,
+				Image:           svc.Image,
+				Command:         svc.Entrypoint,
+				Args:            svc.Command,
+				User:            svc.User,
+				Dir:             svc.WorkingDir,
+				Hostname:        svc.Hostname,
+				Env:             mapToSlice(svc.Env),
+				Secrets:         secretRefs,
+				Healthcheck:     svc.Healthcheck,
+				Mounts:          svc.Mounts,
+				StopSignal:      svc.StopSignal,
+				StopGracePeriod: svc.StopGracePeriod,
 			},
-			Networks: parseNetworks(networks),
+			Resources:     svc.Resources,
+			RestartPolicy: svc.RestartPolicy,
+			Networks:      parseNetworks(svc.Networks),
 			LogDriver: &swarm.Driver{
 				Name: "local",
 				Options: map[string]string{
@@ -62,40 +79,25 @@ func DeployService(
 				},
 			},
 		},
-		Mode: swarm.ServiceMode{
-			Replicated: &swarm.ReplicatedService{
-				Replicas: &replicas,
-			},
-		},
-		UpdateConfig: &swarm.UpdateConfig{
-			Parallelism:     1,
-			FailureAction:   swarm.UpdateFailureActionRollback,
-			MaxFailureRatio: 0.0,
-			Monitor:         10 * time.Second,
-			Order:           order,
-		},
-		RollbackConfig: &swarm.UpdateConfig{
-			Parallelism:     1,
-			FailureAction:   swarm.UpdateFailureActionPause,
-			MaxFailureRatio: 0.0,
-			Monitor:         10 * time.Second,
-			Order:           order,
-		},
+		Mode:           svc.Mode,
+		EndpointSpec:   &swarm.EndpointSpec{Ports: svc.Ports},
+		UpdateConfig:   svc.UpdateConfig,
+		RollbackConfig: svc.RollbackConfig,
 	}
 
-	existingService, err := FindService(ctx, docker, name)
+	existingService, err := FindService(ctx, docker, svc.Name)
 	if err != nil {
 		return err
 	}
 
 	if existingService != nil {
-		fmt.Fprintf(out, "dockboy: updating service '%s'...\n", name)
+		fmt.Fprintf(out, "dockboy: updating service '%s'...\n", svc.Name)
 		_, err = docker.ServiceUpdate(ctx, existingService.ID, existingService.Version, spec, types.ServiceUpdateOptions{})
 		if err != nil {
 			return fmt.Errorf("service update failed: %w", err)
 		}
 	} else {
-		fmt.Fprintf(out, "dockboy: creating service '%s'...\n", name)
+		fmt.Fprintf(out, "dockboy: creating service '%s'...\n", svc.Name)
 		resp, err := docker.ServiceCreate(ctx, spec, types.ServiceCreateOptions{})
 		if err != nil {
 			return fmt.Errorf("service creation failed: %w", err)
@@ -106,6 +108,12 @@ func DeployService(
 	return WaitForService(ctx, out, docker, existingService.ID)
 }
 
+// maxTaskFailures is the number of failed tasks after which a service that
+// is not being updated is considered to not start.
+const maxTaskFailures = 3
+
+// WaitForService waits until the tasks of the service are running or its
+// update finished. It fails if the tasks of a new service keep failing.
 func WaitForService(ctx context.Context, out io.Writer, docker *client.Client, serviceID string) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -113,7 +121,7 @@ func WaitForService(ctx context.Context, out io.Writer, docker *client.Client, s
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
 
-	done := make(chan struct{})
+	done := make(chan error, 1)
 	msgs, errs := docker.Events(ctx, events.ListOptions{
 		Filters: filters.NewArgs(filters.Arg("type", "container")),
 	})
@@ -122,8 +130,8 @@ func WaitForService(ctx context.Context, out io.Writer, docker *client.Client, s
 	go checkService(ctx, out, docker, serviceID, done)
 
 	select {
-	case <-done:
-		return nil
+	case err := <-done:
+		return err
 	case <-signalChan:
 		cancel()
 		return fmt.Errorf("deployment cancelled")
@@ -147,7 +155,7 @@ func monitorEvents(ctx context.Context, out io.Writer, docker *client.Client, ms
 	}
 }
 
-func checkService(ctx context.Context, out io.Writer, docker *client.Client, serviceID string, done chan struct{}) {
+func checkService(ctx context.Context, out io.Writer, docker *client.Client, serviceID string, done chan<- error) {
 	ticker := time.NewTicker(500 * time.Millisecond)
 	defer ticker.Stop()
 
@@ -178,16 +186,47 @@ func checkService(ctx context.Context, out io.Writer, docker *client.Client, ser
 				}
 
 				running := true
+				runningTasks := 0
+				failedTasks := 0
 				for _, task := range tasks {
-					if task.DesiredState == swarm.TaskStateRunning && task.Status.State != swarm.TaskStateRunning {
+					// Tasks of earlier versions of the service failed
+					// before it was updated.
+					failed := task.Status.State == swarm.TaskStateFailed || task.Status.State == swarm.TaskStateRejected
+					if failed && !task.CreatedAt.Before(service.UpdatedAt) {
+						failedTasks++
+					}
+					if task.DesiredState != swarm.TaskStateRunning {
+						continue
+					}
+					if task.Status.State != swarm.TaskStateRunning {
 						running = false
 						break
 					}
+					runningTasks++
 				}
 
-				if running {
+				// Global services have no replica count in the spec, so the
+				// number of desired tasks comes from the service status.
+				status, err := ServiceStatus(ctx, docker, service.ID)
+				if err != nil {
+					fmt.Fprintf(out, "dockboy: service status error: %v\n", err)
+					continue
+				}
+
+				if running && uint64(runningTasks) >= status.DesiredTasks {
 					fmt.Fprintf(out, "dockboy: service '%s' is running.\n", service.Spec.Name)
-					close(done)
+					done <- nil
+					return
+				}
+
+				// A new service has no update to roll back, so Swarm keeps
+				// restarting tasks that crash.
+				if failedTasks >= maxTaskFailures {
+					taskErr, err := getLatestTaskError(ctx, docker, serviceID)
+					if err != nil {
+						taskErr = err.Error()
+					}
+					done <- fmt.Errorf("service '%s' failed to start, %d tasks failed: %s", service.Spec.Name, failedTasks, taskErr)
 					return
 				}
 			}
@@ -196,22 +235,22 @@ func checkService(ctx context.Context, out io.Writer, docker *client.Client, ser
 				switch service.UpdateStatus.State {
 				case swarm.UpdateStateCompleted:
 					fmt.Fprintf(out, "dockboy: service '%s' updated successfully.\n", service.Spec.Name)
-					close(done)
+					done <- nil
 					return
 				case swarm.UpdateStatePaused:
 					fmt.Fprintf(out, "dockboy: service '%s' update paused.\n", service.Spec.Name)
-					close(done)
+					done <- nil
 					return
 				case swarm.UpdateStateRollbackCompleted:
 					// deploy might start with a rollback completed state if the previous update failed.
 					if lastState == swarm.UpdateStateRollbackStarted || lastState == swarm.UpdateStateRollbackPaused {
 						fmt.Fprintf(out, "dockboy: service '%s' rolled back successfully.\n", service.Spec.Name)
-						close(done)
+						done <- nil
 						return
 					}
 				case swarm.UpdateStateRollbackPaused:
 					fmt.Fprintf(out, "dockboy: service '%s' rollback paused.\n", service.Spec.Name)
-					close(done)
+					done <- nil
 					return
 				case swarm.UpdateStateRollbackStarted:
 					fmt.Fprintf(out, "dockboy: service '%s' update failed, rolling back. message: %s\n", service.Spec.Name, service.UpdateStatus.Message)
@@ -0,0 +1,139 @@
+package dockerhelper
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+)
+
+// SendImage copies the image from the local Docker daemon to the remote one,
+// unless the remote one already has it.
+func SendImage(ctx context.Context, out io.Writer, remote *client.Client, imageName string) error {
+	fmt.Fprintln(out, "dockboy: sending image to remote host...")
+
+	local, err := client.NewClientWithOpts(client.FromEnv)
+	if err != nil {
+		return fmt.Errorf("failed to create local Docker client: %w", err)
+	}
+	defer local.Close()
+
+	inspect, _, err := local.ImageInspectWithRaw(ctx, imageName)
+	if err != nil {
+		return fmt.Errorf("failed to inspect image on local client: %w", err)
+	}
+
+	remoteImages, err := remote.ImageList(ctx, image.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list images on remote client: %w", err)
+	}
+
+	for _, img := range remoteImages {
+		if img.ID == inspect.ID {
+			return nil
+		}
+	}
+
+	reader, err := local.ImageSave(ctx, []string{imageName})
+	if err != nil {
+		return fmt.Errorf("failed to save image on source: %w", err)
+	}
+	defer reader.Close()
+
+	resp, err := remote.ImageLoad(ctx, reader, true)
+	if err != nil {
+		return fmt.Errorf("failed to load image on remote client: %w", err)
+	}
+	defer resp.Body.Close()
+
+	_, err = io.Copy(io.Discard, resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response from remote client: %w", err)
+	}
+
+	return nil
+}
+
+// ImageExists reports whether the Docker daemon has the image.
+func ImageExists(ctx context.Context, docker *client.Client, imageName string) (bool, error) {
+	_, _, err := docker.ImageInspectWithRaw(ctx, imageName)
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to inspect image: %w", err)
+	}
+
+	return true, nil
+}
+
+// Platform returns the platform of the Docker daemon, e.g. linux/amd64, so
+// images built on another machine run on it.
+func Platform(ctx context.Context, docker *client.Client) (string, error) {
+	info, err := docker.Info(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get Docker info: %w", err)
+	}
+
+	// Info reports the architecture like uname, images use the names of Go.
+	arch := info.Architecture
+	switch arch {
+	case "x86_64":
+		arch = "amd64"
+	case "aarch64":
+		arch = "arm64"
+	case "armv7l":
+		arch = "arm/v7"
+	case "armv6l":
+		arch = "arm/v6"
+	case "i386", "i686":
+		arch = "386"
+	}
+
+	return info.OSType + "/" + arch, nil
+}
+
+// BuildImage builds an image for the platform from a Dockerfile without any
+// other files in the build context, and writes the build output to out.
+func BuildImage(ctx context.Context, out io.Writer, docker *client.Client, tag, platform, dockerfile string) error {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{
+		Name: "Dockerfile",
+		Mode: 0o644,
+		Size: int64(len(dockerfile)),
+	}); err != nil {
+		return fmt.Errorf("failed to create build context: %w", err)
+	}
+	if _, err := tw.Write([]byte(dockerfile)); err != nil {
+		return fmt.Errorf("failed to create build context: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to create build context: %w", err)
+	}
+
+	resp, err := docker.ImageBuild(ctx, &buf, types.ImageBuildOptions{
+		Tags:        []string{tag},
+		Dockerfile:  "Dockerfile",
+		Platform:    platform,
+		PullParent:  true,
+		Remove:      true,
+		ForceRemove: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build image %s: %w", tag, err)
+	}
+	defer resp.Body.Close()
+
+	if err := jsonmessage.DisplayJSONMessagesStream(resp.Body, out, 0, false, nil); err != nil {
+		return fmt.Errorf("failed to build image %s: %w", tag, err)
+	}
+
+	return nil
+}
@@ -5,56 +5,140 @@ import (
 	"strings"
 
 	"github.com/d3witt/dockboy/sshexec"
-	"golang.org/x/crypto/ssh"
 )
 
-func IsDockerInstalled(c *ssh.Client) bool {
-	cmd := sshexec.Command(c, "docker", "info")
+// Privilege is how the SSH user may use Docker on the machine.
+type Privilege int
+
+const (
+	// PrivilegeNone means the user can't use Docker.
+	PrivilegeNone Privilege = iota
+	// PrivilegeDocker means the user is root or in the docker group.
+	PrivilegeDocker
+	// PrivilegeSudo means the user may run Docker with passwordless sudo.
+	PrivilegeSudo
+)
+
+// IsDockerInstalled reports whether the Docker CLI is installed. Use
+// DockerPrivilege to check whether the user may use it.
+func IsDockerInstalled(c *sshexec.Client) bool {
+	cmd := sshexec.Command(c, "command", "-v", "docker")
 	return cmd.Run() == nil
 }
 
-func isSuperUser(c *ssh.Client) bool {
+// DockerPrivilege detects how the user may talk to the Docker daemon.
+func DockerPrivilege(c *sshexec.Client) Privilege {
+	if sshexec.Command(c, "docker", "info").Run() == nil {
+		return PrivilegeDocker
+	}
+	if sshexec.Command(c, "sudo", "-n", "docker", "info").Run() == nil {
+		return PrivilegeSudo
+	}
+	return PrivilegeNone
+}
+
+func isRoot(c *sshexec.Client) bool {
+	uid, err := sshexec.Command(c, "id", "-u").Output()
+	return err == nil && strings.TrimSpace(uid) == "0"
+}
+
+func isSuperUser(c *sshexec.Client) bool {
 	cmd := sshexec.Command(c, "sudo", "-n", "true")
 	return cmd.Run() == nil
 }
 
-func InstallDocker(c *ssh.Client) error {
-	if !isSuperUser(c) {
-		return fmt.Errorf("not a super user")
+// privileged runs the command as root, with sudo unless the user is root.
+func privileged(c *sshexec.Client, root bool, name string, args ...string) *sshexec.Cmd {
+	if root {
+		return sshexec.Command(c, name, args...)
+	}
+	return sshexec.Command(c, "sudo", append([]string{"-n", name}, args...)...)
+}
+
+func InstallDocker(c *sshexec.Client) error {
+	root := isRoot(c)
+	if !root && !isSuperUser(c) {
+		return fmt.Errorf("not a super user, allow passwordless sudo or connect as root")
+	}
+
+	sudo := "sudo -n "
+	if root {
+		sudo = ""
 	}
 
-	installCmd := `
-        curl -fsSL https://get.docker.com | sudo sh || \
-        wget -qO- https://get.docker.com | sudo sh || \
+	installCmd := fmt.Sprintf(`
+        curl -fsSL https://get.docker.com | %[1]ssh || \
+        wget -qO- https://get.docker.com | %[1]ssh || \
         exit 1
-    `
+    `, sudo)
 
 	if err := sshexec.Command(c, installCmd).Run(); err != nil {
 		return fmt.Errorf("failed to install Docker: %w", err)
 	}
 
-	if err := configureDockerLogging(c); err != nil {
+	if err := configureDockerLogging(c, root); err != nil {
 		return fmt.Errorf("failed to configure Docker logging: %w", err)
 	}
 
 	setupCmds := [][]string{
-		{"sudo", "systemctl", "enable", "docker.service"},
-		{"sudo", "systemctl", "enable", "containerd.service"},
-		{"sudo", "systemctl", "restart", "docker.service"},
-		{"sudo", "docker", "run", "--privileged", "--rm", "tonistiigi/binfmt", "--install", "all"},
+		{"systemctl", "enable", "docker.service"},
+		{"systemctl", "enable", "containerd.service"},
+		{"systemctl", "restart", "docker.service"},
+		{"docker", "run", "--privileged", "--rm", "tonistiigi/binfmt", "--install", "all"},
 	}
 
 	for _, args := range setupCmds {
-		cmd := sshexec.Command(c, args[0], args[1:]...)
+		cmd := privileged(c, root, args[0], args[1:]...)
 		if err := cmd.Run(); err != nil {
 			return fmt.Errorf("failed to execute %v: %w", args, err)
 		}
 	}
 
+	if !root {
+		if err := addToDockerGroup(c); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func configureDockerLogging(c *ssh.Client) error {
+// EnsureDockerAccess makes sure the user may use Docker. Users with sudo are
+// added to the docker group, so they can open the Docker socket and the admin
+// sockets of Caddy. It reports whether the user was added.
+func EnsureDockerAccess(c *sshexec.Client) (bool, error) {
+	switch DockerPrivilege(c) {
+	case PrivilegeNone:
+		return false, fmt.Errorf("user can't use Docker, add it to the docker group or allow passwordless sudo")
+	case PrivilegeSudo:
+		if err := addToDockerGroup(c); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// addToDockerGroup adds the user to the docker group and reconnects, since
+// the current connection keeps the groups the user had when it logged in.
+func addToDockerGroup(c *sshexec.Client) error {
+	user, err := sshexec.Command(c, "id", "-un").Output()
+	if err != nil {
+		return fmt.Errorf("failed to get user name: %w", err)
+	}
+	user = strings.TrimSpace(user)
+
+	if err := sshexec.Command(c, "sudo", "-n", "groupadd", "-f", "docker").Run(); err != nil {
+		return fmt.Errorf("failed to create docker group: %w", err)
+	}
+	if err := sshexec.Command(c, "sudo", "-n", "usermod", "-aG", "docker", user).Run(); err != nil {
+		return fmt.Errorf("failed to add %s to the docker group: %w", user, err)
+	}
+
+	return c.Reconnect()
+}
+
+func configureDockerLogging(c *sshexec.Client, root bool) error {
 	config := `{
 	    "log-driver": "local",
 	    "log-opts": {
@@ -63,7 +147,7 @@ func configureDockerLogging(c *ssh.Client) error {
 	    }
 	}`
 
-	cmd := sshexec.Command(c, "sudo", "mkdir", "-p", "/etc/docker")
+	cmd := privileged(c, root, "mkdir", "-p", "/etc/docker")
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("failed to create docker config directory: %w", err)
 	}
@@ -74,7 +158,7 @@ func configureDockerLogging(c *ssh.Client) error {
 		return fmt.Errorf("failed to write temp docker config: %w", err)
 	}
 
-	cmd = sshexec.Command(c, "sudo", "mv", tmpFile, "/etc/docker/daemon.json")
+	cmd = privileged(c, root, "mv", tmpFile, "/etc/docker/daemon.json")
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("failed to move docker config: %w", err)
 	}
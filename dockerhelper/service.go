@@ -3,6 +3,7 @@ package dockerhelper
 import (
 	"context"
 	"fmt"
+	"slices"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/filters"
@@ -18,11 +19,50 @@ func FindService(ctx context.Context, remote *client.Client, name string) (*swar
 		return nil, fmt.Errorf("failed to list services: %w", err)
 	}
 
-	if len(services) == 0 {
-		return nil, nil
+	// The name filter matches prefixes, so look for the exact name.
+	for _, service := range services {
+		if service.Spec.Name == name {
+			return &service, nil
+		}
 	}
 
-	return &services[0], nil
+	return nil, nil
+}
+
+// FindAppServices returns all services of the app: the service named after
+// the app and, for blue-green deployments, its green counterpart.
+func FindAppServices(ctx context.Context, remote *client.Client, name string) ([]swarm.Service, error) {
+	var services []swarm.Service
+	for _, serviceName := range []string{name, name + GreenServiceSuffix} {
+		service, err := FindService(ctx, remote, serviceName)
+		if err != nil {
+			return nil, err
+		}
+		if service != nil {
+			services = append(services, *service)
+		}
+	}
+
+	return services, nil
+}
+
+// FindAppService returns the service currently serving the app. Both colors
+// only exist at the same time when removing the previous one failed after a
+// blue-green switch, so the newest service is the live one.
+func FindAppService(ctx context.Context, remote *client.Client, name string) (*swarm.Service, error) {
+	services, err := FindAppServices(ctx, remote, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var live *swarm.Service
+	for i := range services {
+		if live == nil || services[i].CreatedAt.After(live.CreatedAt) {
+			live = &services[i]
+		}
+	}
+
+	return live, nil
 }
 
 func ListTasks(ctx context.Context, remote *client.Client, serviceID string) ([]swarm.Task, error) {
@@ -35,3 +75,57 @@ func ListTasks(ctx context.Context, remote *client.Client, serviceID string) ([]
 
 	return tasks, nil
 }
+
+func ServiceStatus(ctx context.Context, remote *client.Client, serviceID string) (*swarm.ServiceStatus, error) {
+	services, err := remote.ServiceList(ctx, types.ServiceListOptions{
+		Filters: filters.NewArgs(filters.Arg("id", serviceID)),
+		Status:  true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services: %w", err)
+	}
+
+	if len(services) == 0 || services[0].ServiceStatus == nil {
+		return nil, fmt.Errorf("service %s not found", serviceID)
+	}
+
+	return services[0].ServiceStatus, nil
+}
+
+// CheckPortConflicts returns an error if another service already publishes
+// one of the ports. Services named in exclude are ignored, so an app can be
+// updated without conflicting with itself.
+func CheckPortConflicts(ctx context.Context, remote *client.Client, ports []swarm.PortConfig, exclude ...string) error {
+	if len(ports) == 0 {
+		return nil
+	}
+
+	services, err := remote.ServiceList(ctx, types.ServiceListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list services: %w", err)
+	}
+
+	for _, service := range services {
+		if slices.Contains(exclude, service.Spec.Name) || service.Spec.EndpointSpec == nil {
+			continue
+		}
+
+		for _, used := range service.Spec.EndpointSpec.Ports {
+			for _, port := range ports {
+				if used.PublishedPort == port.PublishedPort && portProtocol(used) == portProtocol(port) {
+					return fmt.Errorf("port %d/%s is already published by service '%s'", port.PublishedPort, portProtocol(port), service.Spec.Name)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// portProtocol defaults to TCP like Docker does.
+func portProtocol(port swarm.PortConfig) swarm.PortConfigProtocol {
+	if port.Protocol == "" {
+		return swarm.PortConfigProtocolTCP
+	}
+	return port.Protocol
+}
@@ -12,7 +12,6 @@ import (
 
 	"github.com/d3witt/dockboy/sshexec"
 	"github.com/docker/docker/client"
-	"golang.org/x/crypto/ssh"
 )
 
 // cmdConn implements net.Conn interface over ssh command
@@ -62,27 +61,33 @@ func (c *cmdConn) SetWriteDeadline(t time.Time) error {
 	return nil
 }
 
-func DialSSH(sshClient *ssh.Client) (*client.Client, error) {
-	dialContext := func(ctx context.Context, network, addr string) (net.Conn, error) {
-		cmd := sshexec.Command(sshClient, "docker", "system", "dial-stdio")
-		inWriter, err := cmd.StdinPipe()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get stdin pipe: %w", err)
+// dockerSocket is where the Docker daemon listens on the machine.
+const dockerSocket = "/var/run/docker.sock"
+
+// DialSSH returns a Docker client talking to the daemon over the SSH
+// connection. If the user may open the Docker socket, connections are
+// forwarded to it through direct-streamlocal channels. Otherwise each
+// connection runs "docker system dial-stdio" on the machine, with sudo if the
+// user needs it.
+func DialSSH(sshClient *sshexec.Client) (*client.Client, error) {
+	var dialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+	if conn, err := sshClient.Dial("unix", dockerSocket); err == nil {
+		conn.Close()
+		dialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return sshClient.Dial("unix", dockerSocket)
 		}
-		outReader, err := cmd.StdoutPipe()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get stdout pipe: %w", err)
-		}
-
-		if err := cmd.Start(); err != nil {
-			return nil, fmt.Errorf("failed to start command: %w", err)
+	} else {
+		slog.Debug("Docker socket is not reachable, falling back to dial-stdio", "error", err)
+
+		// Users outside the docker group may still use Docker with sudo.
+		switch DockerPrivilege(sshClient) {
+		case PrivilegeDocker:
+			dialContext = dialStdio(sshClient, false)
+		case PrivilegeSudo:
+			dialContext = dialStdio(sshClient, true)
+		default:
+			return nil, fmt.Errorf("user can't use Docker on %s, add it to the docker group or allow passwordless sudo", sshClient.RemoteAddr())
 		}
-
-		return &cmdConn{
-			cmd: cmd,
-			in:  outReader,
-			out: inWriter,
-		}, nil
 	}
 
 	httpClient := &http.Client{
@@ -90,6 +95,10 @@ func DialSSH(sshClient *ssh.Client) (*client.Client, error) {
 		// No proxy
 		Transport: &http.Transport{
 			DialContext: dialContext,
+			// Keep connections around, so API calls share them instead of
+			// opening a channel each.
+			MaxIdleConnsPerHost: 8,
+			IdleConnTimeout:     time.Minute,
 		},
 	}
 
@@ -111,3 +120,30 @@ func DialSSH(sshClient *ssh.Client) (*client.Client, error) {
 
 	return client.NewClientWithOpts(clientOpts...)
 }
+
+func dialStdio(sshClient *sshexec.Client, sudo bool) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		cmd := sshexec.Command(sshClient, "docker", "system", "dial-stdio")
+		if sudo {
+			cmd = sshexec.Command(sshClient, "sudo", "-n", "docker", "system", "dial-stdio")
+		}
+		inWriter, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get stdin pipe: %w", err)
+		}
+		outReader, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get stdout pipe: %w", err)
+		}
+
+		if err := cmd.Start(); err != nil {
+			return nil, fmt.Errorf("failed to start command: %w", err)
+		}
+
+		return &cmdConn{
+			cmd: cmd,
+			in:  outReader,
+			out: inWriter,
+		}, nil
+	}
+}
@@ -4,4 +4,7 @@ const (
 	SwarmLabel             = "dockboy-swarm"
 	DockboyInternalNetwork = "dockboy-internal"
 	DockboyPublicNetwork   = "dockboy-public"
+	GreenServiceSuffix     = "-green"
+	CanaryServiceSuffix    = "-canary"
+	CanaryWeightLabel      = "dockboy.canary.weight"
 )
@@ -9,6 +9,7 @@ import (
 	"github.com/d3witt/dockboy/cli/command"
 	"github.com/d3witt/dockboy/cli/command/app"
 	"github.com/d3witt/dockboy/cli/command/machine"
+	"github.com/d3witt/dockboy/cli/command/proxy"
 	"github.com/d3witt/dockboy/streams"
 	"github.com/urfave/cli/v2"
 )
@@ -41,11 +42,15 @@ func main() {
 		Commands: []*cli.Command{
 			app.NewInitCmd(dockboyCli),
 			app.NewDeployCmd(dockboyCli),
+			app.NewCanaryCmd(dockboyCli),
+			app.NewMaintenanceCmd(dockboyCli),
 			app.NewLogsCommand(dockboyCli),
 			app.NewDestroyCmd(dockboyCli),
 			app.NewInfoCmd(dockboyCli),
 			machine.NewPurgeCmd(dockboyCli),
 			machine.NewExecuteCmd(dockboyCli),
+			proxy.NewProxyCmd(dockboyCli),
+			proxy.NewCertsCmd(dockboyCli),
 		},
 		Suggest:   true,
 		Reader:    dockboyCli.In,
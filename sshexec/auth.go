@@ -0,0 +1,327 @@
+package sshexec
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// maxPassphraseAttempts is how often a wrong passphrase may be entered.
+const maxPassphraseAttempts = 3
+
+// Authentication methods, named as in the PreferredAuthentications option of
+// ssh_config.
+const (
+	AuthPublicKey           = "publickey"
+	AuthKeyboardInteractive = "keyboard-interactive"
+	AuthPassword            = "password"
+)
+
+// DefaultAuthMethods is the order OpenSSH tries the methods in.
+var DefaultAuthMethods = []string{AuthPublicKey, AuthKeyboardInteractive, AuthPassword}
+
+var errNoKeys = errors.New("no SSH keys found, set identity_file or add a key to ssh-agent")
+
+// Prompter asks the user for secrets while authenticating.
+type Prompter interface {
+	// Password asks for the password of user on host.
+	Password(user, host string) (string, error)
+	// Challenge answers keyboard-interactive questions, see
+	// ssh.KeyboardInteractiveChallenge.
+	Challenge(name, instruction string, questions []string, echos []bool) ([]string, error)
+}
+
+// authMethods returns the authentication methods of the host, in order. The
+// returned function releases what the methods hold on to, such as the
+// connection to the SSH agent, once the handshake is done.
+func authMethods(host Host) ([]ssh.AuthMethod, func(), error) {
+	names := host.AuthMethods
+	if len(names) == 0 {
+		names = DefaultAuthMethods
+	}
+
+	var methods []ssh.AuthMethod
+	var skipped error
+	release := func() {}
+	for _, name := range names {
+		switch name {
+		case AuthPublicKey:
+			method, closeAgent, err := publicKeys(host)
+			if errors.Is(err, errNoKeys) && len(names) > 1 {
+				// Other methods may still work.
+				slog.Debug("Skipping public key authentication", "host", host.Host, "error", err)
+				skipped = err
+				continue
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			methods = append(methods, method)
+			release = closeAgent
+		case AuthKeyboardInteractive:
+			if host.Prompter == nil {
+				continue
+			}
+			methods = append(methods, ssh.KeyboardInteractive(host.Prompter.Challenge))
+		case AuthPassword:
+			if host.Prompter == nil {
+				continue
+			}
+			prompter := host.Prompter
+			methods = append(methods, ssh.RetryableAuthMethod(ssh.PasswordCallback(func() (string, error) {
+				return prompter.Password(host.User, host.Host)
+			}), maxPassphraseAttempts))
+		default:
+			release()
+			return nil, nil, fmt.Errorf("unsupported authentication method: %s", name)
+		}
+	}
+
+	if len(methods) == 0 && skipped != nil {
+		return nil, nil, skipped
+	}
+	if len(methods) == 0 {
+		return nil, nil, fmt.Errorf("no authentication methods available for %s", host.Host)
+	}
+
+	return methods, release, nil
+}
+
+// PassphraseProvider supplies the passphrase of an encrypted identity file.
+type PassphraseProvider interface {
+	// Passphrase returns a passphrase to try. retry is set if the previous
+	// one was wrong.
+	Passphrase(file string, retry bool) ([]byte, error)
+	// Accepted is called with the passphrase that decrypted the key.
+	Accepted(file string, passphrase []byte)
+}
+
+// publicKeys authenticates with the keys of the SSH agent first, then with
+// the identity file. An encrypted identity file is only decrypted if the
+// server accepts its public key, so no passphrase is asked for if a key of
+// the agent is enough. The returned function closes the connection to the
+// agent.
+func publicKeys(host Host) (ssh.AuthMethod, func(), error) {
+	agentSigners, closeAgent := agentSigners()
+
+	var fileSigner ssh.Signer
+	if host.IdentityFile != "" {
+		var err error
+		fileSigner, err = identitySigner(host.IdentityFile, host.Passphrase)
+		if err != nil {
+			closeAgent()
+			return nil, nil, err
+		}
+	}
+
+	if agentSigners == nil && fileSigner == nil {
+		return nil, nil, errNoKeys
+	}
+
+	return ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+		var signers []ssh.Signer
+		if agentSigners != nil {
+			var err error
+			signers, err = agentSigners()
+			if err != nil {
+				slog.Debug("Failed to get keys from SSH agent", "error", err)
+			}
+		}
+
+		if fileSigner != nil && !containsKey(signers, fileSigner.PublicKey()) {
+			signers = append(signers, fileSigner)
+		}
+		return signers, nil
+	}), closeAgent, nil
+}
+
+// agentSigners returns a function listing the keys of the SSH agent, or nil
+// if no agent is running. The keys sign through the connection to the agent,
+// so it must stay open until the handshake is done, then the second function
+// closes it.
+func agentSigners() (func() ([]ssh.Signer, error), func()) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, func() {}
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		slog.Debug("Failed to connect to SSH agent", "socket", socket, "error", err)
+		return nil, func() {}
+	}
+
+	agentClient := agent.NewClient(conn)
+	return agentClient.Signers, func() { conn.Close() }
+}
+
+func containsKey(signers []ssh.Signer, key ssh.PublicKey) bool {
+	for _, signer := range signers {
+		if bytes.Equal(signer.PublicKey().Marshal(), key.Marshal()) {
+			return true
+		}
+	}
+	return false
+}
+
+// identitySigner reads an identity file, and its OpenSSH certificate if there
+// is one next to it, like ssh does.
+func identitySigner(file string, passphrase PassphraseProvider) (ssh.Signer, error) {
+	signer, err := keySigner(file, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	certFile := file + "-cert.pub"
+	data, err := os.ReadFile(certFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return signer, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate: %w", err)
+	}
+
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate %s: %w", certFile, err)
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a certificate", certFile)
+	}
+	if cert.CertType != ssh.UserCert {
+		return nil, fmt.Errorf("%s is not a user certificate", certFile)
+	}
+	if before := cert.ValidBefore; before != ssh.CertTimeInfinity && time.Now().After(time.Unix(int64(before), 0)) {
+		return nil, fmt.Errorf("certificate %s expired on %s", certFile, time.Unix(int64(before), 0).Format(time.RFC3339))
+	}
+
+	certSigner, err := ssh.NewCertSigner(cert, signer)
+	if err != nil {
+		return nil, fmt.Errorf("certificate %s does not match %s: %w", certFile, file, err)
+	}
+
+	return certSigner, nil
+}
+
+// keySigner reads a private key. If it is encrypted, the returned signer asks
+// for the passphrase the first time it signs.
+func keySigner(file string, passphrase PassphraseProvider) (ssh.Signer, error) {
+	key, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identity file: %w", err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(key)
+	var missing *ssh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse identity file %s: %w", file, err)
+		}
+		return signer, nil
+	}
+
+	if passphrase == nil {
+		return nil, fmt.Errorf("identity file %s is encrypted, add it to ssh-agent", file)
+	}
+
+	s := &encryptedSigner{file: file, key: key, passphrase: passphrase, pub: missing.PublicKey}
+	if s.pub == nil {
+		s.pub = readPublicKey(file + ".pub")
+	}
+	if s.pub == nil {
+		// Without the public key the server can't be asked whether it
+		// accepts the key, so decrypt it right away.
+		return s.decrypt()
+	}
+
+	return s, nil
+}
+
+func readPublicKey(file string) ssh.PublicKey {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil
+	}
+	return pub
+}
+
+// encryptedSigner decrypts an identity file when it is first used to sign.
+type encryptedSigner struct {
+	file       string
+	key        []byte
+	pub        ssh.PublicKey
+	passphrase PassphraseProvider
+
+	mu     sync.Mutex
+	signer ssh.AlgorithmSigner
+}
+
+func (s *encryptedSigner) PublicKey() ssh.PublicKey {
+	return s.pub
+}
+
+func (s *encryptedSigner) Algorithms() []string {
+	return keyAlgorithms(s.pub)
+}
+
+func (s *encryptedSigner) Sign(rand io.Reader, data []byte) (*ssh.Signature, error) {
+	return s.SignWithAlgorithm(rand, data, "")
+}
+
+func (s *encryptedSigner) SignWithAlgorithm(rand io.Reader, data []byte, algorithm string) (*ssh.Signature, error) {
+	signer, err := s.decrypt()
+	if err != nil {
+		return nil, err
+	}
+	return signer.SignWithAlgorithm(rand, data, algorithm)
+}
+
+func (s *encryptedSigner) decrypt() (ssh.AlgorithmSigner, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.signer != nil {
+		return s.signer, nil
+	}
+
+	for attempt := 0; attempt < maxPassphraseAttempts; attempt++ {
+		passphrase, err := s.passphrase.Passphrase(s.file, attempt > 0)
+		if err != nil {
+			return nil, err
+		}
+
+		signer, err := ssh.ParsePrivateKeyWithPassphrase(s.key, passphrase)
+		if errors.Is(err, x509.IncorrectPasswordError) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse identity file %s: %w", s.file, err)
+		}
+
+		algorithmSigner, ok := signer.(ssh.AlgorithmSigner)
+		if !ok {
+			return nil, fmt.Errorf("unsupported key type of identity file %s", s.file)
+		}
+
+		s.passphrase.Accepted(s.file, passphrase)
+		s.signer = algorithmSigner
+		return s.signer, nil
+	}
+
+	return nil, fmt.Errorf("wrong passphrase for identity file %s", s.file)
+}
@@ -3,77 +3,105 @@ package sshexec
 import (
 	"fmt"
 	"net"
-	"os"
 	"strconv"
 	"time"
 
 	"golang.org/x/crypto/ssh"
-	"golang.org/x/crypto/ssh/agent"
 )
 
-func SSHClient(host string, port int, user, private, passphrase string) (*ssh.Client, error) {
-	var sshAuth ssh.AuthMethod
-	var err error
+// Host describes how to reach and authenticate with an SSH server.
+type Host struct {
+	Host         string
+	Port         int
+	User         string
+	IdentityFile string
+	// Passphrase is asked for the passphrase of an encrypted identity file.
+	Passphrase PassphraseProvider
+	// AuthMethods are the methods tried in order, see AuthPublicKey and the
+	// other Auth constants. If empty, DefaultAuthMethods are tried.
+	AuthMethods []string
+	// Prompter asks for passwords and keyboard-interactive answers.
+	Prompter Prompter
 
-	if private != "" {
-		sshAuth, err = authorizeWithKey(private, passphrase)
-	} else {
-		sshAuth, err = authorizeWithSSHAgent()
-	}
-	if err != nil {
-		return nil, err
-	}
+	HostKeyCallback ssh.HostKeyCallback
+	// HostKeyAlgorithms may be nil to let the server pick the type of its
+	// host key.
+	HostKeyAlgorithms []string
+}
 
-	// Set up SSH client configuration
-	config := &ssh.ClientConfig{
-		User: user,
-		Auth: []ssh.AuthMethod{
-			sshAuth,
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         time.Second * 30,
+func (h Host) addr() string {
+	return net.JoinHostPort(h.Host, strconv.Itoa(h.Port))
+}
+
+// SSHClient connects to the target through the jump hosts, in order. Closing
+// the returned client closes the connections to the jump hosts too.
+func SSHClient(target Host, jumps ...Host) (*ssh.Client, error) {
+	var clients []*ssh.Client
+	closeAll := func() {
+		for i := len(clients) - 1; i >= 0; i-- {
+			clients[i].Close()
+		}
 	}
 
-	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	for _, host := range append(jumps, target) {
+		config, release, err := clientConfig(host)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
 
-	return ssh.Dial("tcp", addr, config)
-}
+		if len(clients) == 0 {
+			client, err := ssh.Dial("tcp", host.addr(), config)
+			release()
+			if err != nil {
+				return nil, err
+			}
+			clients = append(clients, client)
+			continue
+		}
 
-func authorizeWithKey(key, passphrase string) (ssh.AuthMethod, error) {
-	var signer ssh.Signer
-	var err error
+		via := clients[len(clients)-1]
+		conn, err := via.Dial("tcp", host.addr())
+		if err != nil {
+			release()
+			closeAll()
+			return nil, fmt.Errorf("failed to reach %s through %s: %w", host.addr(), via.RemoteAddr(), err)
+		}
 
-	if passphrase != "" {
-		signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(key), []byte(passphrase))
-	} else {
-		signer, err = ssh.ParsePrivateKey([]byte(key))
+		c, chans, reqs, err := ssh.NewClientConn(conn, host.addr(), config)
+		release()
+		if err != nil {
+			conn.Close()
+			closeAll()
+			return nil, err
+		}
+		clients = append(clients, ssh.NewClient(c, chans, reqs))
 	}
-	if err != nil {
-		return nil, err
+
+	client := clients[len(clients)-1]
+	if len(clients) > 1 {
+		go func() {
+			client.Wait()
+			closeAll()
+		}()
 	}
 
-	return ssh.PublicKeys(signer), nil
+	return client, nil
 }
 
-func authorizeWithSSHAgent() (ssh.AuthMethod, error) {
-	socket := os.Getenv("SSH_AUTH_SOCK")
-	if socket == "" {
-		return nil, fmt.Errorf("SSH_AUTH_SOCK environment variable is not set")
-	}
-
-	conn, err := net.Dial("unix", socket)
+// clientConfig returns the config to connect to the host with. The returned
+// function must be called once the handshake is done, see authMethods.
+func clientConfig(host Host) (*ssh.ClientConfig, func(), error) {
+	auth, release, err := authMethods(host)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to SSH_AUTH_SOCK: %w", err)
+		return nil, nil, err
 	}
 
-	agentClient := agent.NewClient(conn)
-
-	return ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
-		signers, err := agentClient.Signers()
-		if err != nil {
-			conn.Close()
-			return nil, err
-		}
-		return signers, nil
-	}), nil
+	return &ssh.ClientConfig{
+		User:              host.User,
+		Auth:              auth,
+		HostKeyCallback:   host.HostKeyCallback,
+		HostKeyAlgorithms: host.HostKeyAlgorithms,
+		Timeout:           time.Second * 30,
+	}, release, nil
 }
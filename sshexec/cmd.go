@@ -25,7 +25,7 @@ func (e ExitError) Error() string {
 }
 
 type Cmd struct {
-	client  *ssh.Client
+	client  *Client
 	session *ssh.Session
 	logger  *slog.Logger
 
@@ -40,7 +40,7 @@ type Cmd struct {
 	pipes []io.Closer
 }
 
-func Command(client *ssh.Client, name string, args ...string) *Cmd {
+func Command(client *Client, name string, args ...string) *Cmd {
 	return &Cmd{
 		client: client,
 		Name:   name,
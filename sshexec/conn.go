@@ -0,0 +1,204 @@
+package sshexec
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	keepaliveInterval = 15 * time.Second
+	// keepaliveTimeout is how long a keepalive may go unanswered before the
+	// connection is considered lost.
+	keepaliveTimeout = 3 * keepaliveInterval
+)
+
+var errClientClosed = errors.New("ssh client closed")
+
+// Client is a single SSH connection shared by everything a command does on
+// the machine. It sends keepalives and reconnects when the connection is
+// lost, so long running commands survive network hiccups.
+type Client struct {
+	dial func() (*ssh.Client, error)
+
+	mu     sync.Mutex
+	conn   *ssh.Client
+	closed bool
+}
+
+// Connect dials the machine and keeps the connection alive. dial is called
+// again to reconnect.
+func Connect(dial func() (*ssh.Client, error)) (*Client, error) {
+	c := &Client{dial: dial}
+	if _, err := c.Conn(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Conn returns the underlying connection, reconnecting if it was lost.
+func (c *Client) Conn() (*ssh.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, errClientClosed
+	}
+	if c.conn != nil {
+		return c.conn, nil
+	}
+
+	conn, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+
+	go c.keepalive(conn)
+
+	return conn, nil
+}
+
+// keepalive pings the server until the connection is closed, and drops the
+// connection if the server stops answering.
+func (c *Client) keepalive(conn *ssh.Client) {
+	done := make(chan struct{})
+	go func() {
+		conn.Wait()
+		close(done)
+		c.drop(conn)
+	}()
+
+	ticker := time.NewTicker(keepaliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if !alive(conn) {
+				slog.Debug("SSH connection lost", "machine", conn.RemoteAddr().String())
+				conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// alive reports whether the server answers a keepalive in time.
+func alive(conn *ssh.Client) bool {
+	result := make(chan error, 1)
+	go func() {
+		_, _, err := conn.SendRequest("keepalive@openssh.com", true, nil)
+		result <- err
+	}()
+
+	select {
+	case err := <-result:
+		return err == nil
+	case <-time.After(keepaliveTimeout):
+		return false
+	}
+}
+
+// drop forgets the connection, so the next call reconnects.
+func (c *Client) drop(conn *ssh.Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == conn {
+		c.conn = nil
+	}
+}
+
+// retry runs fn on the connection. If fn fails because the connection was
+// lost, it reconnects and runs fn once more.
+func (c *Client) retry(fn func(*ssh.Client) error) error {
+	conn, err := c.Conn()
+	if err != nil {
+		return err
+	}
+
+	err = fn(conn)
+	if err == nil || alive(conn) {
+		return err
+	}
+
+	slog.Debug("Reconnecting to machine", "machine", conn.RemoteAddr().String(), "error", err)
+	conn.Close()
+	c.drop(conn)
+
+	conn, err = c.Conn()
+	if err != nil {
+		return fmt.Errorf("failed to reconnect: %w", err)
+	}
+	return fn(conn)
+}
+
+// Reconnect replaces the connection with a new one. The server reads the
+// groups of the user on login, so this picks up groups it was just added to.
+func (c *Client) Reconnect() error {
+	c.mu.Lock()
+	conn := c.conn
+	c.conn = nil
+	c.mu.Unlock()
+
+	if conn != nil {
+		conn.Close()
+	}
+
+	if _, err := c.Conn(); err != nil {
+		return fmt.Errorf("failed to reconnect: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) NewSession() (*ssh.Session, error) {
+	var session *ssh.Session
+	err := c.retry(func(conn *ssh.Client) error {
+		var err error
+		session, err = conn.NewSession()
+		return err
+	})
+	return session, err
+}
+
+// Dial opens a connection from the machine, e.g. to a unix socket through a
+// direct-streamlocal channel.
+func (c *Client) Dial(network, addr string) (net.Conn, error) {
+	var netConn net.Conn
+	err := c.retry(func(conn *ssh.Client) error {
+		var err error
+		netConn, err = conn.Dial(network, addr)
+		return err
+	})
+	return netConn, err
+}
+
+func (c *Client) RemoteAddr() net.Addr {
+	conn, err := c.Conn()
+	if err != nil {
+		return &net.TCPAddr{}
+	}
+	return conn.RemoteAddr()
+}
+
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	if c.conn == nil {
+		return nil
+	}
+
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
@@ -0,0 +1,208 @@
+package sshexec
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// ConfirmHostKey asks the user whether to trust the key of a host that is not
+// in known_hosts yet.
+type ConfirmHostKey func(host string, key ssh.PublicKey) (bool, error)
+
+// HostKeyMismatchError is returned when a host presents a different key than
+// the one recorded for it.
+type HostKeyMismatchError struct {
+	Host   string
+	Got    ssh.PublicKey
+	Want   ssh.PublicKey
+	Source string
+}
+
+func (e *HostKeyMismatchError) Error() string {
+	return fmt.Sprintf("host key mismatch for %s: the server sent %s %s, but %s expects %s %s. "+
+		"Someone could be intercepting the connection, or the server was reinstalled. "+
+		"If the change is expected, update %s",
+		e.Host, e.Got.Type(), ssh.FingerprintSHA256(e.Got),
+		e.Source, e.Want.Type(), ssh.FingerprintSHA256(e.Want), e.Source)
+}
+
+// DefaultKnownHostsFile returns the path of the user's known_hosts file.
+func DefaultKnownHostsFile() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find home directory: %w", err)
+	}
+	return filepath.Join(home, ".ssh", "known_hosts"), nil
+}
+
+// PinnedHostKey accepts only the given key, in authorized_keys format
+// ("ssh-ed25519 AAAA...") or as a SHA256 fingerprint ("SHA256:...").
+func PinnedHostKey(pinned string) (ssh.HostKeyCallback, error) {
+	pinned = strings.TrimSpace(pinned)
+
+	if strings.HasPrefix(pinned, "SHA256:") {
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if ssh.FingerprintSHA256(key) == pinned {
+				return nil
+			}
+			return fmt.Errorf("host key mismatch for %s: the server sent %s %s, but host_key is pinned to %s",
+				hostname, key.Type(), ssh.FingerprintSHA256(key), pinned)
+		}, nil
+	}
+
+	want, _, _, _, err := ssh.ParseAuthorizedKey([]byte(pinned))
+	if err != nil {
+		return nil, fmt.Errorf("invalid host_key: %w", err)
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if bytes.Equal(key.Marshal(), want.Marshal()) {
+			return nil
+		}
+		return &HostKeyMismatchError{Host: hostname, Got: key, Want: want, Source: "host_key"}
+	}, nil
+}
+
+// PinnedHostKeyAlgorithms returns the algorithms of the pinned key, so the
+// server is asked for that key instead of one of another type. It returns nil
+// for a fingerprint, which does not tell the key type.
+func PinnedHostKeyAlgorithms(pinned string) []string {
+	want, _, _, _, err := ssh.ParseAuthorizedKey([]byte(strings.TrimSpace(pinned)))
+	if err != nil {
+		return nil
+	}
+	return keyAlgorithms(want)
+}
+
+// KnownHosts verifies host keys against a known_hosts file. Unknown hosts are
+// added to the file if confirm accepts their key.
+func KnownHosts(file string, confirm ConfirmHostKey) (ssh.HostKeyCallback, error) {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		// Read the file on every call, so hosts added by an earlier
+		// connection, e.g. a jump host, are known.
+		callback, err := loadKnownHosts(file)
+		if err != nil {
+			return err
+		}
+
+		err = callback(hostname, remote, key)
+
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+
+		if len(keyErr.Want) > 0 {
+			want := keyErr.Want[0]
+			for _, k := range keyErr.Want {
+				if k.Key.Type() == key.Type() {
+					want = k
+					break
+				}
+			}
+			return &HostKeyMismatchError{
+				Host:   hostname,
+				Got:    key,
+				Want:   want.Key,
+				Source: fmt.Sprintf("%s:%d", want.Filename, want.Line),
+			}
+		}
+
+		if confirm == nil {
+			return fmt.Errorf("host key of %s (%s %s) is not in %s", hostname, key.Type(), ssh.FingerprintSHA256(key), file)
+		}
+
+		trusted, err := confirm(hostname, key)
+		if err != nil {
+			return err
+		}
+		if !trusted {
+			return fmt.Errorf("host key of %s was not trusted", hostname)
+		}
+
+		return addKnownHost(file, hostname, remote, key)
+	}, nil
+}
+
+// KnownHostKeyAlgorithms returns the algorithms of the keys known for the
+// host, so the server is asked for a key we can verify instead of one of
+// another type. It returns nil if the host is unknown.
+func KnownHostKeyAlgorithms(file, addr string) []string {
+	callback, err := loadKnownHosts(file)
+	if err != nil {
+		return nil
+	}
+
+	// No host has this key, so the error lists the keys known for addr.
+	placeholder, err := ssh.NewPublicKey(ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)))
+	if err != nil {
+		return nil
+	}
+
+	var keyErr *knownhosts.KeyError
+	if !errors.As(callback(addr, &net.TCPAddr{}, placeholder), &keyErr) {
+		return nil
+	}
+
+	var algorithms []string
+	for _, k := range keyErr.Want {
+		algorithms = append(algorithms, keyAlgorithms(k.Key)...)
+	}
+	return algorithms
+}
+
+// keyAlgorithms returns the signature algorithms of a key. RSA keys sign with
+// SHA-2 too, which servers prefer.
+func keyAlgorithms(key ssh.PublicKey) []string {
+	if key.Type() == ssh.KeyAlgoRSA {
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	}
+	return []string{key.Type()}
+}
+
+func loadKnownHosts(file string) (ssh.HostKeyCallback, error) {
+	callback, err := knownhosts.New(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			return &knownhosts.KeyError{}
+		}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	return callback, nil
+}
+
+func addKnownHost(file, hostname string, remote net.Addr, key ssh.PublicKey) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(file), err)
+	}
+
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", file, err)
+	}
+	defer f.Close()
+
+	addresses := []string{knownhosts.Normalize(hostname)}
+	if tcp, ok := remote.(*net.TCPAddr); ok && tcp.IP != nil {
+		if ip := knownhosts.Normalize(remote.String()); ip != addresses[0] {
+			addresses = append(addresses, ip)
+		}
+	}
+
+	if _, err := fmt.Fprintln(f, knownhosts.Line(addresses, key)); err != nil {
+		return fmt.Errorf("failed to write %s: %w", file, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package sshexec
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"slices"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestPinnedHostKeyAlgorithms(t *testing.T) {
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edKey, err := ssh.NewPublicKey(edPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := ssh.NewPublicKey(&rsaPriv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		pinned string
+		want   []string
+	}{
+		{
+			name:   "ed25519",
+			pinned: string(ssh.MarshalAuthorizedKey(edKey)),
+			want:   []string{ssh.KeyAlgoED25519},
+		},
+		{
+			name:   "rsa",
+			pinned: string(ssh.MarshalAuthorizedKey(rsaKey)),
+			want:   []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA},
+		},
+		{
+			name:   "fingerprint",
+			pinned: ssh.FingerprintSHA256(edKey),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PinnedHostKeyAlgorithms(tt.pinned); !slices.Equal(got, tt.want) {
+				t.Errorf("PinnedHostKeyAlgorithms() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
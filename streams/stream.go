@@ -34,3 +34,8 @@ func (s *stream) Restore() error {
 func (s *stream) Size() (width int, height int, err error) {
 	return term.GetSize(s.fd)
 }
+
+// ReadPassword reads a line without echoing it.
+func (s *stream) ReadPassword() ([]byte, error) {
+	return term.ReadPassword(s.fd)
+}